@@ -0,0 +1,55 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+// Package executor defines the common interface of the goroutine pools in this module,
+// gopool.Pool and the pools of workpool both implement it,
+// so libraries can accept an Executor without depending on a specific pool.
+package executor
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrStopped is returned when a function is submitted to a stopped executor
+var ErrStopped = errors.New("executor: stopped")
+
+// Stats is a snapshot of the counters of an executor
+type Stats struct {
+	WorkersAlive   int64 // 当前存活的worker数
+	WorkersCreated int64 // 创建过的worker数
+	WorkersKilled  int64 // 退出的worker数
+	TasksSubmitted int64 // 提交的任务数
+	TasksCompleted int64 // 执行完成的任务数，包括panic的任务
+	TasksPanicked  int64 // 执行时panic的任务数
+	TasksDiscarded int64 // 未执行就被丢弃的任务数
+}
+
+// Executor runs functions asynchronously on a bounded set of goroutines.
+// A panic in f is recovered and reported to the executor's recover function,
+// it never crashes the worker.
+type Executor interface {
+	// Execute submits f, it blocks while the executor is full until ctx is done.
+	// If ctx is already done, f is accepted only if there is room right now.
+	// f gets a context which is cancelled when the executor is force shut down.
+	Execute(ctx context.Context, f func(ctx context.Context)) error
+	// Shutdown stops accepting functions and waits for the submitted ones until ctx is done,
+	// then cancels the context of the running ones and returns ctx.Err().
+	// Functions not started yet are dropped.
+	Shutdown(ctx context.Context) error
+	// Stats returns the counters of the executor
+	Stats() Stats
+}
@@ -22,6 +22,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/go-chassis/foundation/executor"
 )
 
 func TestGoRoutine_Do(t *testing.T) {
@@ -116,3 +118,47 @@ func TestGoRoutine_Exception2(t *testing.T) {
 	})
 	CloseAndWait()
 }
+
+func TestGoRoutine_Execute(t *testing.T) {
+	test := New(Configure().Workers(1))
+	block := make(chan struct{})
+	if err := test.Execute(context.Background(), func(ctx context.Context) { <-block }); err != nil {
+		t.Fatalf("execute failed: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := test.Execute(ctx, func(ctx context.Context) {}); err != context.DeadlineExceeded {
+		t.Fatalf("execute should time out when workers are busy, got %v", err)
+	}
+	cancelled := make(chan struct{})
+	test2 := New(Configure().Workers(1))
+	test2.Execute(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := test2.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("shutdown should time out, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("job context should be cancelled after shutdown timed out")
+	}
+
+	close(block)
+	test.Execute(context.Background(), func(ctx context.Context) {
+		panic("boom")
+	})
+	if err := test.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %s", err)
+	}
+	stats := test.Stats()
+	if stats.TasksSubmitted != 2 || stats.TasksCompleted != 2 || stats.TasksPanicked != 1 || stats.WorkersAlive != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if err := test.Execute(context.Background(), func(ctx context.Context) {}); err != executor.ErrStopped {
+		t.Fatalf("execute should fail after shutdown, got %v", err)
+	}
+}
@@ -22,7 +22,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-chassis/foundation/executor"
 	"github.com/go-chassis/foundation/timeutil"
+	"go.uber.org/atomic"
 )
 
 var GlobalConfig = Configure()
@@ -41,10 +43,28 @@ type Pool struct {
 	mux    sync.RWMutex
 	wg     sync.WaitGroup
 	closed bool
+
+	// counters of Stats
+	workersAlive   atomic.Int64
+	workersCreated atomic.Int64
+	workersKilled  atomic.Int64
+	tasksSubmitted atomic.Int64
+	tasksCompleted atomic.Int64
+	tasksPanicked  atomic.Int64
 }
 
+var _ executor.Executor = (*Pool)(nil)
+
 func (g *Pool) execute(f func(ctx context.Context)) {
-	defer g.logRecover()
+	defer g.tasksCompleted.Inc()
+	defer func() {
+		if r := recover(); r != nil {
+			g.tasksPanicked.Inc()
+			if g.Cfg.RecoverFunc != nil {
+				g.Cfg.RecoverFunc(r)
+			}
+		}
+	}()
 	f(g.ctx)
 }
 
@@ -63,16 +83,63 @@ func (g *Pool) Do(f func(context.Context)) *Pool {
 		select {
 		case g.pending <- f: // block if workers are busy
 		case g.workers <- struct{}{}:
-			g.wg.Add(1)
-			go g.loop(f)
+			g.startWorker(f)
 		}
 	}
+	g.tasksSubmitted.Inc()
 	return g
 }
 
+// Execute is Do with a context to give up waiting for an idle goroutine,
+// it returns executor.ErrStopped if the pool is closed
+func (g *Pool) Execute(ctx context.Context, f func(context.Context)) (err error) {
+	g.mux.RLock()
+	closed := g.closed
+	g.mux.RUnlock()
+	if closed {
+		return executor.ErrStopped
+	}
+	defer func() {
+		// the pool is closed while waiting
+		if r := recover(); r != nil {
+			err = executor.ErrStopped
+		}
+	}()
+	select {
+	case g.pending <- f:
+	default:
+		select {
+		case g.pending <- f:
+		case g.workers <- struct{}{}:
+			g.startWorker(f)
+		default:
+			select {
+			case g.pending <- f:
+			case g.workers <- struct{}{}:
+				g.startWorker(f)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	g.tasksSubmitted.Inc()
+	return nil
+}
+
+func (g *Pool) startWorker(f func(context.Context)) {
+	g.wg.Add(1)
+	g.workersAlive.Inc()
+	g.workersCreated.Inc()
+	go g.loop(f)
+}
+
 func (g *Pool) loop(f func(context.Context)) {
 	defer g.wg.Done()
 	defer func() { <-g.workers }()
+	defer func() {
+		g.workersAlive.Dec()
+		g.workersKilled.Inc()
+	}()
 
 	timer := time.NewTimer(g.Cfg.IdleTimeout)
 	defer timer.Stop()
@@ -109,6 +176,45 @@ func (g *Pool) Close(grace bool) {
 	}
 }
 
+// Shutdown closes the pool and waits for all goroutines complete the jobs until ctx is done,
+// then cancels the context of the jobs
+func (g *Pool) Shutdown(ctx context.Context) error {
+	g.mux.Lock()
+	if g.closed {
+		g.mux.Unlock()
+		return nil
+	}
+	g.closed = true
+	g.mux.Unlock()
+
+	close(g.pending)
+	close(g.workers)
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	defer g.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the counters of the pool
+func (g *Pool) Stats() executor.Stats {
+	return executor.Stats{
+		WorkersAlive:   g.workersAlive.Load(),
+		WorkersCreated: g.workersCreated.Load(),
+		WorkersKilled:  g.workersKilled.Load(),
+		TasksSubmitted: g.tasksSubmitted.Load(),
+		TasksCompleted: g.tasksCompleted.Load(),
+		TasksPanicked:  g.tasksPanicked.Load(),
+	}
+}
+
 // Done will wait for all goroutines complete the jobs and then close the pool
 func (g *Pool) Done() {
 	g.mux.Lock()
@@ -0,0 +1,357 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response kept by a CacheStore
+type CachedResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	// VaryHeader holds the request headers named by the Vary header of the response
+	VaryHeader http.Header
+	Stored     time.Time
+	// Expires is the end of freshness, the response is revalidated after it
+	Expires time.Time
+}
+
+func (c *CachedResponse) size() int64 {
+	n := int64(len(c.Body))
+	for k, v := range c.Header {
+		n += int64(len(k))
+		for _, s := range v {
+			n += int64(len(s))
+		}
+	}
+	return n
+}
+
+// CacheStore keeps cached responses by key, implementations must be safe for concurrent use
+// and must not modify the responses they hold
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+	Delete(key string)
+}
+
+type lruEntry struct {
+	key  string
+	resp *CachedResponse
+	size int64
+}
+
+// LRUCache is an in memory CacheStore evicting the least recently used responses
+type LRUCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+// NewLRUCache creates a store holding at most maxEntries responses of maxBytes in total,
+// a bound not greater than 0 is not checked
+func NewLRUCache(maxEntries int, maxBytes int64) *LRUCache {
+	return &LRUCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (c *LRUCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*lruEntry).resp, true
+	}
+	return nil, false
+}
+
+func (c *LRUCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+	entry := &lruEntry{key: key, resp: resp, size: resp.size()}
+	if c.maxBytes > 0 && entry.size > c.maxBytes {
+		return
+	}
+	c.items[key] = c.ll.PushFront(entry)
+	c.bytes += entry.size
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+}
+
+// Len returns the number of cached responses
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRUCache) removeElement(e *list.Element) {
+	entry := c.ll.Remove(e).(*lruEntry)
+	delete(c.items, entry.key)
+	c.bytes -= entry.size
+}
+
+// HeaderCache is set to HIT on responses served from the cache without a request,
+// and to REVALIDATED on responses served from the cache after a 304
+const HeaderCache = "X-Cache"
+
+// CacheOptions configures CacheInterceptor
+type CacheOptions struct {
+	// Store 为空时使用容量1000条、64MB的LRUCache
+	Store CacheStore
+	// MaxBodySize 超过该大小的响应不缓存，默认1MB
+	MaxBodySize int64
+}
+
+// credentialHeaders identify the caller of a request
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Auth-Token"}
+
+// CacheInterceptor caches GET responses following Cache-Control, Expires, ETag, Last-Modified
+// and Vary. Stale responses are revalidated with conditional requests, a 304 is answered
+// with the cached response. Only one variant of a url is kept.
+// Requests with credentials, such as an Authorization or a Cookie header, only share responses
+// marked public, so the interceptor must run after the ones signing requests
+func CacheInterceptor(opts CacheOptions) Interceptor {
+	if opts.Store == nil {
+		opts.Store = NewLRUCache(1000, 64<<20)
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = 1 << 20
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			reqCC := parseCacheControl(req.Header)
+			if req.Method != http.MethodGet || reqCC.has("no-store") || req.Header.Get("Range") != "" {
+				return next.RoundTrip(req)
+			}
+			key := req.URL.String()
+			// responses to a request with credentials are shared only if they are public
+			auth := hasCredentials(req.Header)
+			cached, ok := opts.Store.Get(key)
+			if ok && (!cached.matchVary(req) || auth && !parseCacheControl(cached.Header).has("public")) {
+				cached, ok = nil, false
+			}
+			if ok && !reqCC.has("no-cache") && time.Now().Before(cached.Expires) {
+				return cached.response(req, "HIT"), nil
+			}
+			send := req
+			if ok && conditionalAllowed(req) {
+				send = req.Clone(req.Context())
+				if etag := cached.Header.Get("ETag"); etag != "" {
+					send.Header.Set("If-None-Match", etag)
+				}
+				if lm := cached.Header.Get("Last-Modified"); lm != "" {
+					send.Header.Set("If-Modified-Since", lm)
+				}
+			}
+			resp, err := next.RoundTrip(send)
+			if err != nil {
+				return nil, err
+			}
+			if ok && send != req && resp.StatusCode == http.StatusNotModified {
+				resp.Body.Close()
+				updated := *cached
+				updated.Header = cached.Header.Clone()
+				for k, v := range resp.Header {
+					updated.Header[k] = v
+				}
+				updated.Stored = time.Now()
+				updated.Expires = freshUntil(updated.Header, updated.Stored)
+				opts.Store.Set(key, &updated)
+				return updated.response(req, "REVALIDATED"), nil
+			}
+			if !cacheable(resp) || auth && !parseCacheControl(resp.Header).has("public") {
+				if ok {
+					opts.Store.Delete(key)
+				}
+				return resp, nil
+			}
+			head, body, truncated, err := peekBody(resp.Body, opts.MaxBodySize)
+			resp.Body = body
+			if err != nil || truncated {
+				return resp, nil
+			}
+			// the whole body is in head, the original body is drained
+			resp.Body.Close()
+			now := time.Now()
+			entry := &CachedResponse{
+				StatusCode: resp.StatusCode,
+				Status:     resp.Status,
+				Header:     resp.Header.Clone(),
+				Body:       head,
+				VaryHeader: varyHeader(resp.Header, req.Header),
+				Stored:     now,
+				Expires:    freshUntil(resp.Header, now),
+			}
+			opts.Store.Set(key, entry)
+			resp.Body = ioutil.NopCloser(bytes.NewReader(head))
+			return resp, nil
+		})
+	}
+}
+
+func hasCredentials(h http.Header) bool {
+	for _, name := range credentialHeaders {
+		if h.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalAllowed reports whether the caller left the conditional headers to the cache
+func conditionalAllowed(req *http.Request) bool {
+	return req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == ""
+}
+
+func (c *CachedResponse) response(req *http.Request, cacheStatus string) *http.Response {
+	h := c.Header.Clone()
+	h.Set(HeaderCache, cacheStatus)
+	h.Set("Age", strconv.Itoa(int(time.Since(c.Stored).Seconds())))
+	return &http.Response{
+		Status:        c.Status,
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+func (c *CachedResponse) matchVary(req *http.Request) bool {
+	for k, v := range c.VaryHeader {
+		if strings.Join(req.Header[k], ",") != strings.Join(v, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func varyHeader(respHeader, reqHeader http.Header) http.Header {
+	h := make(http.Header)
+	for _, v := range respHeader["Vary"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h[http.CanonicalHeaderKey(name)] = reqHeader[http.CanonicalHeaderKey(name)]
+			}
+		}
+	}
+	return h
+}
+
+// cacheable reports whether a response may be stored, it needs a freshness lifetime or a validator
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	// an event stream stays open, reading it to the end would block the caller
+	if mediaType(resp.Header.Get("Content-Type")) == ContentTypeEventStream {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") {
+		return false
+	}
+	if _, ok := varyHeader(resp.Header, nil)["*"]; ok {
+		return false
+	}
+	if resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != "" {
+		return true
+	}
+	return freshUntil(resp.Header, time.Now()).After(time.Now())
+}
+
+// freshUntil returns the end of the freshness lifetime of a response stored at now
+func freshUntil(h http.Header, now time.Time) time.Time {
+	cc := parseCacheControl(h)
+	if cc.has("no-cache") {
+		return now
+	}
+	if v, ok := cc["max-age"]; ok {
+		if secs, err := strconv.Atoi(v); err == nil {
+			return now.Add(time.Duration(secs) * time.Second)
+		}
+		return now
+	}
+	if v := h.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return now
+		}
+		if date, err := http.ParseTime(h.Get("Date")); err == nil {
+			return now.Add(expires.Sub(date))
+		}
+		return expires
+	}
+	return now
+}
+
+type cacheControl map[string]string
+
+func (cc cacheControl) has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := make(cacheControl)
+	for _, v := range h["Cache-Control"] {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if i := strings.Index(part, "="); i > 0 {
+				cc[strings.ToLower(part[:i])] = strings.Trim(part[i+1:], `"`)
+			} else {
+				cc[strings.ToLower(part)] = ""
+			}
+		}
+	}
+	return cc
+}
@@ -0,0 +1,203 @@
+package httpclient_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestCache(t *testing.T) {
+	var hits, notModified atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Inc()
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte("fresh"))
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Inc()
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("etag"))
+		case "/modified":
+			lm := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+			w.Header().Set("Last-Modified", lm)
+			if r.Header.Get("If-Modified-Since") == lm {
+				notModified.Inc()
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte("modified"))
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+			w.Write([]byte(r.Header.Get("Accept-Language")))
+		case "/private":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Write([]byte(r.Header.Get("Authorization")))
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+			w.Write([]byte(r.Header.Get("Authorization")))
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write([]byte("nostore"))
+		}
+	}))
+	defer s.Close()
+	store := httpclient.NewLRUCache(10, 0)
+	c, err := httpclient.New(&httpclient.Options{Cache: &httpclient.CacheOptions{Store: store}})
+	assert.NoError(t, err)
+	get := func(path string, h http.Header) (string, string) {
+		resp, err := c.Get(context.Background(), s.URL+path, h)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		b, _ := ioutil.ReadAll(resp.Body)
+		return string(b), resp.Header.Get(httpclient.HeaderCache)
+	}
+
+	t.Run("fresh response is served from cache", func(t *testing.T) {
+		hits.Store(0)
+		body, status := get("/fresh", nil)
+		assert.Equal(t, "fresh", body)
+		assert.Equal(t, "", status)
+		body, status = get("/fresh", nil)
+		assert.Equal(t, "fresh", body)
+		assert.Equal(t, "HIT", status)
+		assert.Equal(t, int32(1), hits.Load())
+	})
+	t.Run("etag revalidation", func(t *testing.T) {
+		notModified.Store(0)
+		get("/etag", nil)
+		body, status := get("/etag", nil)
+		assert.Equal(t, "etag", body)
+		assert.Equal(t, "REVALIDATED", status)
+		assert.Equal(t, int32(1), notModified.Load())
+	})
+	t.Run("last modified revalidation", func(t *testing.T) {
+		notModified.Store(0)
+		get("/modified", nil)
+		body, status := get("/modified", nil)
+		assert.Equal(t, "modified", body)
+		assert.Equal(t, "REVALIDATED", status)
+		assert.Equal(t, int32(1), notModified.Load())
+	})
+	t.Run("vary", func(t *testing.T) {
+		en := http.Header{"Accept-Language": {"en"}}
+		zh := http.Header{"Accept-Language": {"zh"}}
+		body, _ := get("/vary", en)
+		assert.Equal(t, "en", body)
+		body, status := get("/vary", zh)
+		assert.Equal(t, "zh", body)
+		assert.Equal(t, "", status)
+		body, status = get("/vary", http.Header{"Accept-Language": {"zh"}})
+		assert.Equal(t, "zh", body)
+		assert.Equal(t, "HIT", status)
+	})
+	t.Run("credentials", func(t *testing.T) {
+		alice := http.Header{"Authorization": {"Bearer alice"}}
+		bob := http.Header{"Authorization": {"Bearer bob"}}
+		body, _ := get("/private", alice)
+		assert.Equal(t, "Bearer alice", body)
+		body, status := get("/private", bob)
+		assert.Equal(t, "Bearer bob", body)
+		assert.Equal(t, "", status)
+
+		get("/public", alice)
+		body, status = get("/public", bob)
+		assert.Equal(t, "Bearer alice", body)
+		assert.Equal(t, "HIT", status)
+
+		get("/private", http.Header{"Cookie": {"session=alice"}})
+		_, status = get("/private", http.Header{"Cookie": {"session=bob"}})
+		assert.Equal(t, "", status)
+		get("/private", http.Header{"X-Auth-Token": {"alice"}})
+		_, status = get("/private", http.Header{"X-Auth-Token": {"bob"}})
+		assert.Equal(t, "", status)
+	})
+	t.Run("credentials added by the signer", func(t *testing.T) {
+		// the clients share the store, each signs as a different user
+		signed := func(user string) *httpclient.Requests {
+			c, err := httpclient.New(&httpclient.Options{
+				Cache:  &httpclient.CacheOptions{Store: store},
+				Signer: httpclient.BearerToken(user),
+			})
+			assert.NoError(t, err)
+			return c
+		}
+		for _, user := range []string{"alice", "bob"} {
+			resp, err := signed(user).Get(context.Background(), s.URL+"/private?signed", nil)
+			assert.NoError(t, err)
+			b, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Equal(t, "Bearer "+user, string(b))
+			assert.Equal(t, "", resp.Header.Get(httpclient.HeaderCache))
+		}
+	})
+	t.Run("no-store", func(t *testing.T) {
+		hits.Store(0)
+		get("/nostore", nil)
+		get("/nostore", nil)
+		assert.Equal(t, int32(2), hits.Load())
+		get("/fresh", http.Header{"Cache-Control": {"no-store"}})
+		assert.Equal(t, int32(3), hits.Load())
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	c := httpclient.NewLRUCache(2, 10)
+	c.Set("a", &httpclient.CachedResponse{Body: []byte("1")})
+	c.Set("b", &httpclient.CachedResponse{Body: []byte("2")})
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Set("c", &httpclient.CachedResponse{Body: []byte("3")})
+	_, ok = c.Get("b")
+	assert.False(t, ok, "b is the least recently used")
+	assert.Equal(t, 2, c.Len())
+	c.Set("big", &httpclient.CachedResponse{Body: []byte("0123456789")})
+	assert.Equal(t, 1, c.Len())
+	c.Set("huge", &httpclient.CachedResponse{Body: []byte("0123456789a")})
+	_, ok = c.Get("huge")
+	assert.False(t, ok)
+	c.Delete("big")
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCacheEventStream(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpclient.ContentTypeEventStream)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("data: x\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	c, err := httpclient.New(&httpclient.Options{Cache: &httpclient.CacheOptions{}})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		resp, err := c.Get(ctx, s.URL, nil)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("event stream blocked by the cache")
+	}
+}
@@ -19,15 +19,9 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"errors"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
-	"os"
-	"strings"
-
-	"github.com/go-chassis/foundation/stringutil"
+	"sync"
 )
 
 //SignRequest sign a http request so that it can talk to API server
@@ -56,7 +50,14 @@ func NewGZipBodyReader(body io.ReadCloser) (io.ReadCloser, error) {
 //Requests is a restful client
 type Requests struct {
 	*http.Client
-	options Options
+	options   Options
+	endpoints *EndpointSet
+	limiter   *Limiter
+	chainOnce sync.Once
+	chain     http.RoundTripper
+	// stream shares the options of the client without RequestTimeout
+	streamOnce sync.Once
+	stream     *Requests
 }
 
 func (r *Requests) Get(ctx context.Context, url string, headers http.Header) (resp *http.Response, err error) {
@@ -72,82 +73,76 @@ func (r *Requests) Delete(ctx context.Context, url string, headers http.Header)
 	return r.Do(ctx, "DELETE", url, headers, nil)
 }
 func (r *Requests) Do(ctx context.Context, method string, url string, headers http.Header, body []byte) (resp *http.Response, err error) {
-	if strings.HasPrefix(url, "https") {
-		if transport, ok := r.Client.Transport.(*http.Transport); ok {
-			transport.TLSClientConfig = r.options.TLSConfig
+	return r.DoStream(ctx, method, url, headers, bytes.NewReader(body))
+}
+
+// roundTripper returns the interceptor chain ending with the http client,
+// the chain is built on first use
+func (r *Requests) roundTripper() http.RoundTripper {
+	r.chainOnce.Do(func() {
+		r.chain = r.buildChain()
+	})
+	return r.chain
+}
+
+func (r *Requests) buildChain() http.RoundTripper {
+	interceptors := r.options.Interceptors
+	if interceptors == nil {
+		sign := r.options.SignRequest
+		if r.options.Signer != nil {
+			sign = r.options.Signer.Sign
+		}
+		interceptors = DefaultInterceptors(sign)
+		if r.options.Log != nil {
+			interceptors = []Interceptor{
+				SignInterceptor(sign),
+				LogInterceptor(*r.options.Log),
+				DecompressInterceptor(),
+			}
 		}
 	}
-	if headers == nil {
-		headers = make(http.Header)
+	if r.options.Cache != nil {
+		// after signing, so that the credentials added by Signer or SignRequest keep private responses apart;
+		// copy to keep Options.Interceptors untouched
+		interceptors = append(interceptors[:len(interceptors):len(interceptors)], CacheInterceptor(*r.options.Cache))
 	}
-	if _, ok := headers["Accept"]; !ok {
-		headers["Accept"] = []string{"*/*"}
+	if r.options.Trace != nil {
+		// innermost, so that every attempt is traced; copy to keep Options.Interceptors untouched
+		interceptors = append(interceptors[:len(interceptors):len(interceptors)], TraceInterceptor(*r.options.Trace))
 	}
-	if _, ok := headers["Accept-Encoding"]; !ok && r.options.Compressed {
-		headers["Accept-Encoding"] = []string{"deflate, gzip"}
+	if r.options.Compression != nil {
+		interceptors = append([]Interceptor{CompressInterceptor(*r.options.Compression)}, interceptors...)
 	}
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request failed: %s", err.Error())
-	}
-	req = req.WithContext(ctx)
-	req.Header = headers
-	//sign a request, first use function in r options
-	//if there is not, use global function
-	if r.options.SignRequest != nil {
-		if err = r.options.SignRequest(req); err != nil {
-			return nil, errors.New("Add auth info failed, err: " + err.Error())
-		}
-	} else if SignRequest != nil {
-		if err = SignRequest(req); err != nil {
-			return nil, errors.New("Add auth info failed, err: " + err.Error())
-		}
+	if r.limiter != nil {
+		interceptors = append([]Interceptor{LimitInterceptor(r.limiter)}, interceptors...)
 	}
-	resp, err = r.Client.Do(req)
-	if err != nil {
-		return nil, err
+	if r.endpoints != nil {
+		interceptors = append([]Interceptor{EndpointInterceptor(r.endpoints)}, interceptors...)
 	}
-	switch resp.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err := NewGZipBodyReader(resp.Body)
-		if err != nil {
-			_, err = io.Copy(ioutil.Discard, resp.Body)
-			if err != nil {
-				resp.Body.Close()
-				return nil, err
-			}
-			resp.Body.Close()
-			return nil, err
-		}
-		resp.Body = reader
+	if r.options.Hedge != nil {
+		interceptors = append([]Interceptor{HedgeInterceptor(*r.options.Hedge)}, interceptors...)
 	}
-
-	if os.Getenv("HTTP_DEBUG") == "1" {
-		fmt.Println("--- BEGIN ---")
-		fmt.Printf("> %s %s %s\n", req.Method, req.URL.RequestURI(), req.Proto)
-		for key, header := range req.Header {
-			for _, value := range header {
-				fmt.Printf("> %s: %s\n", key, value)
-			}
-		}
-		fmt.Println(">")
-		fmt.Println(stringutil.Bytes2str(body))
-		fmt.Printf("< %s %s\n", resp.Proto, resp.Status)
-		for key, header := range resp.Header {
-			for _, value := range header {
-				fmt.Printf("< %s: %s\n", key, value)
-			}
-		}
-
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		fmt.Printf("< %s \n", bodyBytes)
-		fmt.Println("--- END ---")
-		resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+	if r.options.Retry != nil {
+		interceptors = append([]Interceptor{RetryInterceptor(*r.options.Retry)}, interceptors...)
 	}
-	return resp, nil
+	return chain(RoundTripFunc(r.Client.Do), interceptors)
+}
+
+// streaming returns a client sending requests whose responses stay open,
+// such as event streams and long polls, without the request timeout, hedging and caching
+func (r *Requests) streaming() *Requests {
+	if r.Client.Timeout == 0 && r.options.Hedge == nil && r.options.Cache == nil {
+		return r
+	}
+	r.streamOnce.Do(func() {
+		c := *r.Client
+		c.Timeout = 0
+		// a request held open would be hedged after Hedge.Delay, and a cache would wait for its end
+		o := r.options
+		o.Hedge, o.Cache = nil, nil
+		r.stream = &Requests{Client: &c, options: o, endpoints: r.endpoints, limiter: r.limiter}
+	})
+	return r.stream
 }
 
 func setOptionDefaultValue(o *Options) Options {
@@ -165,9 +160,6 @@ func setOptionDefaultValue(o *Options) Options {
 	if option.ResponseHeaderTimeout <= 0 {
 		option.ResponseHeaderTimeout = DefaultOptions.ResponseHeaderTimeout
 	}
-	if option.TLSVersion == 0 {
-		option.TLSVersion = DefaultOptions.TLSVersion
-	}
 	if option.ConnsPerHost <= 0 {
 		option.ConnsPerHost = DefaultOptions.ConnsPerHost
 	}
@@ -178,18 +170,25 @@ func setOptionDefaultValue(o *Options) Options {
 //New is a function which which sets client option
 func New(o *Options) (client *Requests, err error) {
 	option := setOptionDefaultValue(o)
+	transport, err := newTransport(option)
+	if err != nil {
+		return nil, err
+	}
 	client = &Requests{
 		Client: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConnsPerHost:   option.ConnsPerHost,
-				TLSHandshakeTimeout:   option.HandshakeTimeout,
-				ResponseHeaderTimeout: option.ResponseHeaderTimeout,
-				DisableCompression:    !option.Compressed,
-			},
-			Timeout: option.RequestTimeout,
+			Transport: transport,
+			Timeout:   option.RequestTimeout,
 		},
 		options: option,
 	}
+	if option.Limit != nil {
+		client.limiter = NewLimiter(*option.Limit)
+	}
+	if option.Endpoints != nil {
+		if client.endpoints, err = NewEndpointSet(*option.Endpoints); err != nil {
+			return nil, err
+		}
+	}
 
 	return
 }
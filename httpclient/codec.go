@@ -0,0 +1,172 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ContentTypeJSON is the content type of JSONCodec
+const ContentTypeJSON = "application/json"
+
+// errorBodyLimit is the most bytes of a response body kept by HTTPError
+const errorBodyLimit = 1024
+
+// Codec encodes request bodies and decodes response bodies of one content type,
+// codecs of other formats such as YAML or protobuf can be added by RegisterCodec
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes with encoding/json
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string                        { return ContentTypeJSON }
+func (JSONCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{ContentTypeJSON: JSONCodec{}}
+)
+
+// RegisterCodec registers c for its content type, it replaces the codec registered before
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[mediaType(c.ContentType())] = c
+}
+
+// GetCodec returns the codec registered for the media type of contentType
+func GetCodec(contentType string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[mediaType(contentType)]
+	return c, ok
+}
+
+func mediaType(contentType string) string {
+	if t, _, err := mime.ParseMediaType(contentType); err == nil {
+		return t
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// HTTPError is returned for non 2xx responses, Body holds at most the first 1KB of the body
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Truncated  bool
+}
+
+func (e *HTTPError) Error() string {
+	if len(e.Body) == 0 {
+		return "httpclient: unexpected status " + e.Status
+	}
+	return fmt.Sprintf("httpclient: unexpected status %s: %s", e.Status, debugBody(e.Body, e.Truncated))
+}
+
+// CheckResponse returns *HTTPError if resp is not 2xx, the body is closed in that case
+func CheckResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+	head, _, truncated, _ := peekBody(resp.Body, errorBodyLimit)
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       head,
+		Truncated:  truncated,
+	}
+}
+
+// GetJSON sends a GET request and decodes the response into out
+func (r *Requests) GetJSON(ctx context.Context, url string, headers http.Header, out interface{}) error {
+	return r.DoWithCodec(ctx, http.MethodGet, url, headers, ContentTypeJSON, nil, out)
+}
+
+// PostJSON sends in encoded as JSON and decodes the response into out
+func (r *Requests) PostJSON(ctx context.Context, url string, headers http.Header, in, out interface{}) error {
+	return r.DoWithCodec(ctx, http.MethodPost, url, headers, ContentTypeJSON, in, out)
+}
+
+// DoWithCodec encodes in with the codec registered for contentType, sends the request
+// and decodes the response into out with the codec matching the response content type,
+// falling back to the request codec. in and out can be nil.
+// A non 2xx response is returned as *HTTPError
+func (r *Requests) DoWithCodec(ctx context.Context, method, url string, headers http.Header, contentType string, in, out interface{}) error {
+	codec, ok := GetCodec(contentType)
+	if !ok {
+		return fmt.Errorf("httpclient: no codec registered for %s", contentType)
+	}
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	if headers.Get("Accept") == "" {
+		headers.Set("Accept", codec.ContentType())
+	}
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = codec.Marshal(in); err != nil {
+			return fmt.Errorf("httpclient: marshal request failed: %w", err)
+		}
+		if headers.Get("Content-Type") == "" {
+			headers.Set("Content-Type", codec.ContentType())
+		}
+	}
+	resp, err := r.DoStream(ctx, method, url, headers, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if err := CheckResponse(resp); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if c, ok := GetCodec(resp.Header.Get("Content-Type")); ok {
+		codec = c
+	}
+	if err := codec.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("httpclient: unmarshal response failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// textCodec stores the body in a *string
+type textCodec struct{}
+
+func (textCodec) ContentType() string                   { return "text/plain" }
+func (textCodec) Marshal(v interface{}) ([]byte, error) { return []byte(*v.(*string)), nil }
+func (textCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*string) = string(data)
+	return nil
+}
+
+func TestJSON(t *testing.T) {
+	type service struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/service":
+			if r.Method == http.MethodPost {
+				assert.Equal(t, httpclient.ContentTypeJSON, r.Header.Get("Content-Type"))
+				var in service
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+				in.Version = "1.0.0"
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				json.NewEncoder(w).Encode(in)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"name":"foo","version":"0.1"}`))
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("plain"))
+		default:
+			w.Header().Set("X-Reason", "missing")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(strings.Repeat("x", 2000)))
+		}
+	}))
+	defer s.Close()
+	c, err := httpclient.New(&httpclient.Options{})
+	assert.NoError(t, err)
+
+	t.Run("get", func(t *testing.T) {
+		var out service
+		assert.NoError(t, c.GetJSON(context.Background(), s.URL+"/service", nil, &out))
+		assert.Equal(t, service{Name: "foo", Version: "0.1"}, out)
+	})
+	t.Run("post", func(t *testing.T) {
+		var out service
+		assert.NoError(t, c.PostJSON(context.Background(), s.URL+"/service", nil, &service{Name: "bar"}, &out))
+		assert.Equal(t, service{Name: "bar", Version: "1.0.0"}, out)
+	})
+	t.Run("http error", func(t *testing.T) {
+		err := c.GetJSON(context.Background(), s.URL+"/none", nil, nil)
+		var he *httpclient.HTTPError
+		assert.True(t, errors.As(err, &he))
+		assert.Equal(t, http.StatusNotFound, he.StatusCode)
+		assert.Equal(t, "missing", he.Header.Get("X-Reason"))
+		assert.Len(t, he.Body, 1024)
+		assert.True(t, he.Truncated)
+	})
+	t.Run("registered codec", func(t *testing.T) {
+		httpclient.RegisterCodec(textCodec{})
+		var out string
+		assert.NoError(t, c.GetJSON(context.Background(), s.URL+"/text", nil, &out))
+		assert.Equal(t, "plain", out)
+		_, ok := httpclient.GetCodec("text/plain; charset=utf-8")
+		assert.True(t, ok)
+		err := c.DoWithCodec(context.Background(), http.MethodGet, s.URL, nil, "application/x-unknown", nil, nil)
+		assert.Error(t, err)
+	})
+}
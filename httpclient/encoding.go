@@ -0,0 +1,257 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Encoding is a content coding of http bodies such as gzip,
+// more codings can be added by RegisterEncoding
+type Encoding interface {
+	// Name is the token used in Content-Encoding
+	Name() string
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+type gzipEncoding struct{}
+
+func (gzipEncoding) Name() string                                 { return "gzip" }
+func (gzipEncoding) NewReader(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) }
+func (gzipEncoding) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// deflateEncoding writes zlib streams as RFC 7230 requires,
+// and reads raw deflate streams sent by some servers too
+type deflateEncoding struct{}
+
+func (deflateEncoding) Name() string { return "deflate" }
+func (deflateEncoding) NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+func (deflateEncoding) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = map[string]Encoding{"gzip": gzipEncoding{}, "deflate": deflateEncoding{}}
+)
+
+// RegisterEncoding registers e by its name, it replaces the encoding registered before
+func RegisterEncoding(e Encoding) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings[strings.ToLower(e.Name())] = e
+}
+
+// GetEncoding returns the encoding registered with name
+func GetEncoding(name string) (Encoding, bool) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	e, ok := encodings[strings.ToLower(strings.TrimSpace(name))]
+	return e, ok
+}
+
+// contentEncodings returns the codings of a Content-Encoding header in the order they were applied
+func contentEncodings(h http.Header) []string {
+	var names []string
+	for _, v := range h["Content-Encoding"] {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && name != "identity" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// decodedBody closes the decoders and the underlying body
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	var err error
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		if e := b.closers[i].Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// decodeBody undoes the codings of names, the last applied coding is removed first.
+// It returns false if a coding is not registered
+func decodeBody(body io.ReadCloser, names []string) (io.ReadCloser, bool, error) {
+	decoders := make([]Encoding, len(names))
+	for i, name := range names {
+		e, ok := GetEncoding(name)
+		if !ok {
+			return body, false, nil
+		}
+		decoders[i] = e
+	}
+	d := &decodedBody{Reader: body, closers: []io.Closer{body}}
+	for i := len(decoders) - 1; i >= 0; i-- {
+		r, err := decoders[i].NewReader(d.Reader)
+		if err != nil {
+			return body, true, err
+		}
+		d.Reader = r
+		d.closers = append(d.closers, r)
+	}
+	return d, true, nil
+}
+
+// DecompressInterceptor decodes response bodies of registered encodings, stacked encodings
+// are decoded in reverse order. Content-Encoding and Content-Length are removed from decoded
+// responses, a response with an unknown encoding is returned untouched
+func DecompressInterceptor() Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			names := contentEncodings(resp.Header)
+			if len(names) == 0 || req.Method == http.MethodHead || resp.StatusCode == http.StatusNoContent ||
+				resp.StatusCode == http.StatusNotModified {
+				return resp, nil
+			}
+			body, ok, err := decodeBody(resp.Body, names)
+			if err != nil {
+				_, _ = io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+				return nil, err
+			}
+			if !ok {
+				return resp, nil
+			}
+			resp.Body = body
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+			resp.Uncompressed = true
+			return resp, nil
+		})
+	}
+}
+
+// CompressionOptions configures CompressInterceptor
+type CompressionOptions struct {
+	// Encoding 请求body的编码，默认gzip
+	Encoding string
+	// MinSize 小于该大小的body不压缩，默认1024；长度未知的流式body总是压缩
+	MinSize int64
+}
+
+// CompressInterceptor compresses request bodies and sets Content-Encoding.
+// Bodies that can be replayed are compressed in memory and stay replayable,
+// streamed bodies are compressed on the fly and sent chunked
+func CompressInterceptor(opts CompressionOptions) Interceptor {
+	if opts.Encoding == "" {
+		opts.Encoding = "gzip"
+	}
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1024
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			enc, ok := GetEncoding(opts.Encoding)
+			if !ok || req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" ||
+				(req.ContentLength >= 0 && req.ContentLength < opts.MinSize) {
+				return next.RoundTrip(req)
+			}
+			r := req.Clone(req.Context())
+			r.Header.Set("Content-Encoding", enc.Name())
+			r.Header.Del("Content-Length")
+			if req.GetBody == nil {
+				r.Body = compressStream(req.Body, enc)
+				r.ContentLength = -1
+				return next.RoundTrip(r)
+			}
+			req.Body.Close()
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			compressed, err := compress(body, enc)
+			if err != nil {
+				return nil, err
+			}
+			r.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+			r.ContentLength = int64(len(compressed))
+			r.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+			}
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+func compress(body io.ReadCloser, enc Encoding) ([]byte, error) {
+	defer body.Close()
+	var buf bytes.Buffer
+	w, err := enc.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressStream compresses body through a pipe, so that the body is never buffered
+func compressStream(body io.ReadCloser, enc Encoding) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+		w, err := enc.NewWriter(pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(w, body); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(w.Close())
+	}()
+	return pr
+}
@@ -0,0 +1,126 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecompress(t *testing.T) {
+	encode := func(w io.Writer, name string) io.WriteCloser {
+		switch name {
+		case "gzip":
+			return gzip.NewWriter(w)
+		case "zlib":
+			return zlib.NewWriter(w)
+		default:
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		switch r.URL.Path {
+		case "/zlib":
+			zw := encode(&buf, "zlib")
+			zw.Write([]byte("hello"))
+			zw.Close()
+			w.Header().Set("Content-Encoding", "deflate")
+		case "/raw":
+			fw := encode(&buf, "raw")
+			fw.Write([]byte("hello"))
+			fw.Close()
+			w.Header().Set("Content-Encoding", "deflate")
+		case "/stacked":
+			var inner bytes.Buffer
+			zw := encode(&inner, "zlib")
+			zw.Write([]byte("hello"))
+			zw.Close()
+			gw := encode(&buf, "gzip")
+			gw.Write(inner.Bytes())
+			gw.Close()
+			w.Header().Set("Content-Encoding", "deflate, gzip")
+		case "/unknown":
+			buf.WriteString("br-data")
+			w.Header().Set("Content-Encoding", "br")
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer s.Close()
+	c, err := httpclient.New(&httpclient.Options{Compressed: true})
+	assert.NoError(t, err)
+	for path, want := range map[string]string{"/zlib": "hello", "/raw": "hello", "/stacked": "hello", "/unknown": "br-data"} {
+		resp, err := c.Get(context.Background(), s.URL+path, nil)
+		assert.NoError(t, err, path)
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err, path)
+		resp.Body.Close()
+		assert.Equal(t, want, string(b), path)
+		if path == "/unknown" {
+			assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+		} else {
+			assert.Equal(t, "", resp.Header.Get("Content-Encoding"), path)
+		}
+	}
+}
+
+func TestCompressRequest(t *testing.T) {
+	type received struct {
+		encoding string
+		length   int64
+		body     string
+	}
+	var last received
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = received{encoding: r.Header.Get("Content-Encoding"), length: r.ContentLength}
+		var body io.Reader = r.Body
+		if last.encoding == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			if !assert.NoError(t, err) {
+				return
+			}
+			body = gr
+		}
+		b, _ := ioutil.ReadAll(body)
+		last.body = string(b)
+	}))
+	defer s.Close()
+	signed := &httpclient.HMACSigner{AccessKey: "ak", SecretKey: "sk"}
+	c, err := httpclient.New(&httpclient.Options{
+		Compression: &httpclient.CompressionOptions{MinSize: 100},
+		Signer:      signed,
+	})
+	assert.NoError(t, err)
+	large := strings.Repeat("a", 1000)
+
+	resp, err := c.Post(context.Background(), s.URL, nil, []byte(large))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "gzip", last.encoding)
+	assert.True(t, last.length > 0 && last.length < 100)
+	assert.Equal(t, large, last.body)
+
+	resp, err = c.Post(context.Background(), s.URL, nil, []byte("small"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "", last.encoding)
+	assert.Equal(t, "small", last.body)
+
+	resp, err = c.DoStream(context.Background(), http.MethodPut, s.URL, nil, onlyReader{strings.NewReader(large)})
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "gzip", last.encoding)
+	assert.Equal(t, int64(-1), last.length)
+	assert.Equal(t, large, last.body)
+}
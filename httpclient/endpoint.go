@@ -0,0 +1,277 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BalancePolicy decides which endpoint serves a request
+type BalancePolicy int
+
+const (
+	// RoundRobin picks endpoints in turn, this is the default policy
+	RoundRobin BalancePolicy = iota
+	// Random picks a random endpoint
+	Random
+	// LeastInFlight picks the endpoint with the fewest requests in flight
+	LeastInFlight
+)
+
+// ErrNoEndpoint is returned when a relative url is requested but no endpoint is configured
+var ErrNoEndpoint = errors.New("httpclient: no endpoint available")
+
+// DefaultFailoverStatusCodes are the response status codes that make the client try another endpoint
+var DefaultFailoverStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// EndpointOptions configures the endpoints relative urls are resolved against
+type EndpointOptions struct {
+	// URLs 后端地址列表，如 https://10.0.0.1:30100
+	URLs   []string
+	Policy BalancePolicy
+	// EjectAfter 连续失败多少次后摘除该地址，默认3
+	EjectAfter int
+	// EjectDuration 摘除时长，到期后放行一个探测请求，成功则恢复，默认30s
+	EjectDuration time.Duration
+	// FailoverStatusCodes 为空时使用DefaultFailoverStatusCodes
+	FailoverStatusCodes []int
+}
+
+type endpoint struct {
+	base         *url.URL
+	inFlight     int
+	failures     int
+	ejectedUntil time.Time
+	probing      bool
+}
+
+// EndpointSet balances requests over a list of base urls and ejects failing ones
+type EndpointSet struct {
+	mu        sync.Mutex
+	opts      EndpointOptions
+	endpoints []*endpoint
+	next      int
+	rand      *rand.Rand
+}
+
+// NewEndpointSet parses the base urls of opts
+func NewEndpointSet(opts EndpointOptions) (*EndpointSet, error) {
+	if len(opts.URLs) == 0 {
+		return nil, ErrNoEndpoint
+	}
+	if opts.EjectAfter <= 0 {
+		opts.EjectAfter = 3
+	}
+	if opts.EjectDuration <= 0 {
+		opts.EjectDuration = 30 * time.Second
+	}
+	if len(opts.FailoverStatusCodes) == 0 {
+		opts.FailoverStatusCodes = DefaultFailoverStatusCodes
+	}
+	s := &EndpointSet{
+		opts: opts,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, raw := range opts.URLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: invalid endpoint %s: %w", raw, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("httpclient: invalid endpoint %s: scheme and host are required", raw)
+		}
+		s.endpoints = append(s.endpoints, &endpoint{base: u})
+	}
+	return s, nil
+}
+
+// pick returns an endpoint not in tried, ejected endpoints are only used
+// as a probe after their ejection expired, or when every endpoint is ejected
+func (s *EndpointSet) pick(tried map[*endpoint]bool) *endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var candidates []*endpoint
+	var fallback *endpoint
+	for _, e := range s.endpoints {
+		if tried[e] {
+			continue
+		}
+		if e.ejectedUntil.IsZero() || (now.After(e.ejectedUntil) && !e.probing) {
+			candidates = append(candidates, e)
+		} else if fallback == nil || e.ejectedUntil.Before(fallback.ejectedUntil) {
+			fallback = e
+		}
+	}
+	var e *endpoint
+	switch {
+	case len(candidates) == 0:
+		e = fallback
+	case s.opts.Policy == Random:
+		e = candidates[s.rand.Intn(len(candidates))]
+	case s.opts.Policy == LeastInFlight:
+		e = candidates[0]
+		for _, c := range candidates[1:] {
+			if c.inFlight < e.inFlight {
+				e = c
+			}
+		}
+	default:
+		e = candidates[s.next%len(candidates)]
+		s.next++
+	}
+	if e == nil {
+		return nil
+	}
+	if !e.ejectedUntil.IsZero() {
+		e.probing = true
+	}
+	e.inFlight++
+	return e
+}
+
+// done records the result of a request sent to e
+func (s *EndpointSet) done(e *endpoint, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.inFlight--
+	e.probing = false
+	if !failed {
+		e.failures = 0
+		e.ejectedUntil = time.Time{}
+		return
+	}
+	e.failures++
+	if e.failures >= s.opts.EjectAfter || !e.ejectedUntil.IsZero() {
+		e.ejectedUntil = time.Now().Add(s.opts.EjectDuration)
+	}
+}
+
+// release decrements the in flight counter without recording a result
+func (s *EndpointSet) release(e *endpoint) {
+	s.mu.Lock()
+	e.inFlight--
+	e.probing = false
+	s.mu.Unlock()
+}
+
+func (s *EndpointSet) failoverStatus(code int) bool {
+	for _, c := range s.opts.FailoverStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve joins the relative url u to the base url
+func (e *endpoint) resolve(u *url.URL) *url.URL {
+	r := *e.base
+	r.Path = strings.TrimSuffix(e.base.Path, "/") + "/" + strings.TrimPrefix(u.Path, "/")
+	r.RawPath = ""
+	r.RawQuery = u.RawQuery
+	r.Fragment = u.Fragment
+	return &r
+}
+
+// EndpointInterceptor resolves relative request urls against the endpoints of set.
+// When an endpoint fails with a connection error or a failover status code,
+// the request is sent to the next endpoint if it is safe to send it again
+func EndpointInterceptor(set *EndpointSet) Interceptor {
+	idempotent := RetryOptions{}.withDefaults()
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.IsAbs() {
+				return next.RoundTrip(req)
+			}
+			canRetry := idempotent.retryable(req)
+			tried := make(map[*endpoint]bool)
+			var (
+				resp    *http.Response
+				lastErr error = ErrNoEndpoint
+			)
+			for {
+				e := set.pick(tried)
+				if e == nil {
+					return resp, lastErr
+				}
+				tried[e] = true
+				r, err := cloneRequest(req)
+				if err != nil {
+					set.release(e)
+					return nil, err
+				}
+				r.URL = e.resolve(req.URL)
+				r.Host = r.URL.Host
+				if resp != nil {
+					// a previous endpoint answered with a failover status
+					resp.Body.Close()
+				}
+				resp, lastErr = next.RoundTrip(r)
+				if lastErr != nil {
+					var ue *url.Error
+					if !errors.As(lastErr, &ue) || req.Context().Err() != nil {
+						set.release(e)
+						return nil, lastErr
+					}
+					set.done(e, true)
+					if !canRetry && !isDialError(lastErr) {
+						return nil, lastErr
+					}
+					continue
+				}
+				failed := set.failoverStatus(resp.StatusCode)
+				resp.Body = &closeHookBody{ReadCloser: resp.Body, done: func() { set.done(e, failed) }}
+				if !failed || !canRetry {
+					return resp, nil
+				}
+			}
+		})
+	}
+}
+
+// isDialError reports whether err happened before the request was sent
+func isDialError(err error) bool {
+	var oe *net.OpError
+	return errors.As(err, &oe) && oe.Op == "dial"
+}
+
+// closeHookBody runs done once the body is closed, so that requests
+// still streaming a response count as in flight
+type closeHookBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *closeHookBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
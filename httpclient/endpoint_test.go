@@ -0,0 +1,106 @@
+package httpclient_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func newNamedServer(name string, status *atomic.Int32, hits *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Inc()
+		w.WriteHeader(int(status.Load()))
+		w.Write([]byte(name + r.URL.Path))
+	}))
+}
+
+func TestEndpoints(t *testing.T) {
+	var statusA, statusB, hitsA, hitsB atomic.Int32
+	statusA.Store(http.StatusOK)
+	statusB.Store(http.StatusOK)
+	a := newNamedServer("a", &statusA, &hitsA)
+	defer a.Close()
+	b := newNamedServer("b", &statusB, &hitsB)
+	defer b.Close()
+
+	t.Run("round robin", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{Endpoints: &httpclient.EndpointOptions{
+			URLs: []string{a.URL, b.URL + "/"},
+		}})
+		assert.NoError(t, err)
+		var got []string
+		for i := 0; i < 4; i++ {
+			resp, err := c.Get(context.Background(), "/v4/ping", nil)
+			assert.NoError(t, err)
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			got = append(got, string(body))
+		}
+		assert.Equal(t, []string{"a/v4/ping", "b/v4/ping", "a/v4/ping", "b/v4/ping"}, got)
+	})
+	t.Run("failover and eject", func(t *testing.T) {
+		statusA.Store(http.StatusServiceUnavailable)
+		defer statusA.Store(http.StatusOK)
+		hitsA.Store(0)
+		c, err := httpclient.New(&httpclient.Options{Endpoints: &httpclient.EndpointOptions{
+			URLs:          []string{a.URL, b.URL},
+			Policy:        httpclient.LeastInFlight,
+			EjectAfter:    1,
+			EjectDuration: 50 * time.Millisecond,
+		}})
+		assert.NoError(t, err)
+		for i := 0; i < 5; i++ {
+			resp, err := c.Get(context.Background(), "/x", nil)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			resp.Body.Close()
+		}
+		// a is ejected after its first failure
+		assert.Equal(t, int32(1), hitsA.Load())
+
+		// a is probed again after the ejection expired and restored
+		statusA.Store(http.StatusOK)
+		time.Sleep(60 * time.Millisecond)
+		for i := 0; i < 4; i++ {
+			resp, err := c.Get(context.Background(), "/x", nil)
+			assert.NoError(t, err)
+			resp.Body.Close()
+		}
+		assert.True(t, hitsA.Load() >= 3)
+	})
+	t.Run("post is not failed over", func(t *testing.T) {
+		statusA.Store(http.StatusServiceUnavailable)
+		defer statusA.Store(http.StatusOK)
+		c, err := httpclient.New(&httpclient.Options{Endpoints: &httpclient.EndpointOptions{
+			URLs: []string{a.URL, b.URL},
+		}})
+		assert.NoError(t, err)
+		resp, err := c.Post(context.Background(), "/x", nil, []byte("x"))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	})
+	t.Run("dial error fails over", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{Endpoints: &httpclient.EndpointOptions{
+			URLs: []string{"http://127.0.0.1:1", b.URL},
+		}})
+		assert.NoError(t, err)
+		resp, err := c.Post(context.Background(), "/x", nil, []byte("x"))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("invalid endpoint", func(t *testing.T) {
+		_, err := httpclient.New(&httpclient.Options{Endpoints: &httpclient.EndpointOptions{
+			URLs: []string{"10.0.0.1:30100"},
+		}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,273 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReplayableBody is a streamed body that can be read again, DoStream uses it
+// to let retries and signers read the body without buffering it
+type ReplayableBody interface {
+	io.Reader
+	// Reopen returns a new reader of the whole body
+	Reopen() (io.ReadCloser, error)
+	// Size returns the length of the body, or -1 if it is unknown
+	Size() int64
+}
+
+// PostForm sends values as an application/x-www-form-urlencoded body
+func (r *Requests) PostForm(ctx context.Context, url string, headers http.Header, values url.Values) (*http.Response, error) {
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.Post(ctx, url, headers, []byte(values.Encode()))
+}
+
+// PostMultipart sends the parts of m as a multipart/form-data body,
+// file contents are streamed and never held in memory
+func (r *Requests) PostMultipart(ctx context.Context, url string, headers http.Header, m *Multipart) (*http.Response, error) {
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set("Content-Type", m.ContentType())
+	if size := m.Size(); size >= 0 {
+		headers.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	return r.DoStream(ctx, http.MethodPost, url, headers, m.Body())
+}
+
+type formPart struct {
+	field       string
+	filename    string
+	contentType string
+	value       string
+	open        func() (io.ReadCloser, error) // nil for a form field
+	size        int64
+}
+
+// Multipart builds a multipart/form-data body of form fields and file parts
+type Multipart struct {
+	boundary   string
+	parts      []formPart
+	replayable bool
+}
+
+// NewMultipart creates an empty multipart body with a random boundary
+func NewMultipart() *Multipart {
+	return &Multipart{
+		boundary:   multipart.NewWriter(ioutil.Discard).Boundary(),
+		replayable: true,
+	}
+}
+
+// ContentType returns the Content-Type header of the body
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// Field adds a form field
+func (m *Multipart) Field(name, value string) *Multipart {
+	m.parts = append(m.parts, formPart{field: name, value: value, size: int64(len(value))})
+	return m
+}
+
+// File adds a file part read from r, r is read once so the body can not be replayed
+func (m *Multipart) File(field, filename string, r io.Reader) *Multipart {
+	size := int64(-1)
+	if l, ok := r.(interface{ Len() int }); ok {
+		size = int64(l.Len())
+	} else if f, ok := r.(*os.File); ok {
+		size = contentLength(f, http.Header{})
+	}
+	used := false
+	m.replayable = false
+	m.parts = append(m.parts, formPart{field: field, filename: filename, size: size,
+		open: func() (io.ReadCloser, error) {
+			if used {
+				return nil, errBodyConsumed
+			}
+			used = true
+			return ioutil.NopCloser(r), nil
+		}})
+	return m
+}
+
+// FileOpener adds a file part read from open every time the body is sent,
+// size is the length of the file or -1 if it is unknown
+func (m *Multipart) FileOpener(field, filename string, size int64, open func() (io.ReadCloser, error)) *Multipart {
+	m.parts = append(m.parts, formPart{field: field, filename: filename, size: size, open: open})
+	return m
+}
+
+// FilePath adds the file at path, it is opened every time the body is sent
+func (m *Multipart) FilePath(field, path string) (*Multipart, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return m, err
+	}
+	return m.FileOpener(field, filepath.Base(path), fi.Size(), func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}), nil
+}
+
+// WithContentType sets the content type of the last file part, the default is application/octet-stream
+func (m *Multipart) WithContentType(contentType string) *Multipart {
+	if n := len(m.parts); n > 0 {
+		m.parts[n-1].contentType = contentType
+	}
+	return m
+}
+
+// Body returns a reader streaming the body, it is a ReplayableBody
+// unless a part was added by File
+func (m *Multipart) Body() io.Reader {
+	if m.replayable {
+		return &multipartBody{m: m}
+	}
+	return &oneShotBody{multipartBody{m: m}}
+}
+
+var errBodyConsumed = errors.New("httpclient: multipart file part can only be read once")
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (p *formPart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	disposition := `form-data; name="` + quoteEscaper.Replace(p.field) + `"`
+	if p.open != nil {
+		disposition += `; filename="` + quoteEscaper.Replace(p.filename) + `"`
+		contentType := p.contentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h.Set("Content-Type", contentType)
+	}
+	h.Set("Content-Disposition", disposition)
+	return h
+}
+
+// write streams all parts to w
+func (m *Multipart) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for i := range m.parts {
+		p := &m.parts[i]
+		pw, err := mw.CreatePart(p.header())
+		if err != nil {
+			return err
+		}
+		if p.open == nil {
+			if _, err := io.WriteString(pw, p.value); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := p.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(pw, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+type countWriter int64
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	*c += countWriter(len(p))
+	return len(p), nil
+}
+
+// Size returns the length of the body, or -1 if the size of a file part is unknown
+func (m *Multipart) Size() int64 {
+	var n countWriter
+	mw := multipart.NewWriter(&n)
+	_ = mw.SetBoundary(m.boundary)
+	var parts int64
+	for i := range m.parts {
+		if m.parts[i].size < 0 {
+			return -1
+		}
+		parts += m.parts[i].size
+		_, _ = mw.CreatePart(m.parts[i].header())
+	}
+	_ = mw.Close()
+	return int64(n) + parts
+}
+
+// multipartBody encodes the parts through a pipe, the encoding starts on the first read
+type multipartBody struct {
+	m    *Multipart
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (b *multipartBody) start() {
+	b.once.Do(func() {
+		pr, pw := io.Pipe()
+		b.pr = pr
+		go func() {
+			pw.CloseWithError(b.m.write(pw))
+		}()
+	})
+}
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.start()
+	return b.pr.Read(p)
+}
+
+// Close stops the encoding if the body is not read to the end
+func (b *multipartBody) Close() error {
+	b.start()
+	return b.pr.Close()
+}
+
+func (b *multipartBody) Reopen() (io.ReadCloser, error) {
+	return &multipartBody{m: b.m}, nil
+}
+
+func (b *multipartBody) Size() int64 {
+	return b.m.Size()
+}
+
+// oneShotBody hides Reopen of a body that can only be read once
+type oneShotBody struct {
+	body multipartBody
+}
+
+func (b *oneShotBody) Read(p []byte) (int, error) { return b.body.Read(p) }
+func (b *oneShotBody) Close() error               { return b.body.Close() }
@@ -0,0 +1,103 @@
+package httpclient_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestForms(t *testing.T) {
+	type received struct {
+		length   int64
+		fields   map[string]string
+		files    map[string]string
+		filename string
+	}
+	var calls atomic.Int32
+	var last received
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" && calls.Inc() == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		last = received{length: r.ContentLength, fields: map[string]string{}, files: map[string]string{}}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			if !assert.NoError(t, r.ParseMultipartForm(1<<20)) {
+				return
+			}
+			for k, v := range r.MultipartForm.Value {
+				last.fields[k] = v[0]
+			}
+			for k, v := range r.MultipartForm.File {
+				f, _ := v[0].Open()
+				b, _ := ioutil.ReadAll(f)
+				f.Close()
+				last.files[k] = string(b)
+				last.filename = v[0].Filename
+			}
+			return
+		}
+		assert.NoError(t, r.ParseForm())
+		for k, v := range r.PostForm {
+			last.fields[k] = v[0]
+		}
+	}))
+	defer s.Close()
+	c, err := httpclient.New(&httpclient.Options{Retry: &httpclient.RetryOptions{
+		MaxAttempts: 2,
+		Backoff:     &backoff.PowerBackoff{InitDelay: time.Millisecond, MaxDelay: time.Millisecond, Factor: 1},
+	}})
+	assert.NoError(t, err)
+
+	t.Run("url encoded form", func(t *testing.T) {
+		resp, err := c.PostForm(context.Background(), s.URL, nil, url.Values{"name": {"foo bar"}, "v": {"1"}})
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, map[string]string{"name": "foo bar", "v": "1"}, last.fields)
+	})
+	t.Run("multipart with file path", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "httpclient")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "artifact.tar")
+		assert.NoError(t, ioutil.WriteFile(path, []byte(strings.Repeat("x", 10000)), 0600))
+
+		m, err := httpclient.NewMultipart().Field("version", "1.0").FilePath("file", path)
+		assert.NoError(t, err)
+		h := http.Header{}
+		h.Set(httpclient.DefaultIdempotencyKeyHeader, "upload-1")
+		calls.Store(0)
+		resp, err := c.PostMultipart(context.Background(), s.URL+"/flaky", h, m)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, int32(2), calls.Load(), "the body is replayed by the retry")
+		assert.Equal(t, m.Size(), last.length)
+		assert.Equal(t, map[string]string{"version": "1.0"}, last.fields)
+		assert.Equal(t, strings.Repeat("x", 10000), last.files["file"])
+		assert.Equal(t, "artifact.tar", last.filename)
+	})
+	t.Run("multipart with one shot reader", func(t *testing.T) {
+		m := httpclient.NewMultipart().
+			File("config", `a"b.yaml`, onlyReader{strings.NewReader("key: value")}).
+			WithContentType("application/yaml")
+		assert.Equal(t, int64(-1), m.Size())
+		resp, err := c.PostMultipart(context.Background(), s.URL, nil, m)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, int64(-1), last.length)
+		assert.Equal(t, "key: value", last.files["config"])
+		assert.Equal(t, `a"b.yaml`, last.filename)
+	})
+}
@@ -0,0 +1,220 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// HeaderHedged is set to "true" on responses answered by the hedged request
+const HeaderHedged = "X-Hedged"
+
+// HedgeOptions configures HedgeInterceptor
+type HedgeOptions struct {
+	// Delay 首个请求超过该时间未响应时发出对冲请求，默认100ms；
+	// 设置Percentile后仅在延迟样本不足时使用
+	Delay time.Duration
+	// Percentile 以最近请求延迟的分位数作为对冲延迟，如0.95，0表示使用固定Delay
+	Percentile float64
+	// MaxRatio 对冲请求数占请求总数的上限，默认0.1
+	MaxRatio float64
+}
+
+const (
+	// DefaultHedgeDelay is used when HedgeOptions.Delay is not set
+	DefaultHedgeDelay = 100 * time.Millisecond
+
+	hedgeSamples    = 256
+	hedgeMinSamples = 20
+)
+
+type hedger struct {
+	opts    HedgeOptions
+	total   atomic.Int64
+	hedged  atomic.Int64
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+// HedgeInterceptor sends a second attempt of an idempotent request if the first one
+// has not answered within the hedge delay. The first successful response wins
+// and the other attempt is cancelled
+func HedgeInterceptor(opts HedgeOptions) Interceptor {
+	if opts.MaxRatio <= 0 {
+		opts.MaxRatio = 0.1
+	}
+	if opts.Delay <= 0 {
+		// a zero delay would hedge every request at once
+		opts.Delay = DefaultHedgeDelay
+	}
+	h := &hedger{opts: opts}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if !hedgeable(req) {
+				return next.RoundTrip(req)
+			}
+			return h.roundTrip(next, req)
+		})
+	}
+}
+
+// hedgeable reports whether req is a read that can be sent twice
+func hedgeable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	}
+	return false
+}
+
+type hedgeResult struct {
+	resp   *http.Response
+	err    error
+	cancel context.CancelFunc
+	hedged bool
+	index  int
+}
+
+func (r hedgeResult) ok() bool {
+	return r.err == nil && r.resp.StatusCode < http.StatusInternalServerError
+}
+
+// discard releases the response and the context of an attempt that lost
+func (r hedgeResult) discard() {
+	if r.resp != nil {
+		_, _ = io.Copy(ioutil.Discard, r.resp.Body)
+		r.resp.Body.Close()
+	}
+	r.cancel()
+}
+
+func (h *hedger) roundTrip(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	total := h.total.Inc()
+	start := time.Now()
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	send := func(hedged bool) error {
+		r, err := cloneRequest(req)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithCancel(req.Context())
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := next.RoundTrip(r.WithContext(ctx))
+			results <- hedgeResult{resp: resp, err: err, cancel: cancel, hedged: hedged, index: index}
+		}()
+		return nil
+	}
+	if err := send(false); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+	pending := 1
+	var last hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			if float64(h.hedged.Load()+1) > h.opts.MaxRatio*float64(total) {
+				continue
+			}
+			if send(true) == nil {
+				h.hedged.Inc()
+				pending++
+			}
+			continue
+		case r := <-results:
+			pending--
+			if !r.hedged && r.ok() {
+				h.observe(time.Since(start))
+			}
+			if !r.ok() && pending > 0 {
+				// wait for the other attempt
+				last = r
+				continue
+			}
+			if last.cancel != nil {
+				last.discard()
+			}
+			if pending > 0 {
+				// cancel the loser now, release its response once it returns
+				for i, cancel := range cancels {
+					if i != r.index {
+						cancel()
+					}
+				}
+				go func() {
+					(<-results).discard()
+				}()
+			}
+			if r.err != nil {
+				r.cancel()
+				return nil, r.err
+			}
+			if r.hedged {
+				if pending > 0 {
+					// the first attempt loses, it would have taken at least this long
+					h.observe(time.Since(start))
+				}
+				r.resp.Header.Set(HeaderHedged, "true")
+			}
+			r.resp.Body = &closeHookBody{ReadCloser: r.resp.Body, done: r.cancel}
+			return r.resp, nil
+		}
+	}
+}
+
+// delay returns the percentile of recent latencies, or the fixed delay
+func (h *hedger) delay() time.Duration {
+	if h.opts.Percentile <= 0 {
+		return h.opts.Delay
+	}
+	h.mu.Lock()
+	if len(h.samples) < hedgeMinSamples {
+		h.mu.Unlock()
+		return h.opts.Delay
+	}
+	sorted := append([]time.Duration(nil), h.samples...)
+	h.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(h.opts.Percentile * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (h *hedger) observe(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) < hedgeSamples {
+		h.samples = append(h.samples, d)
+		return
+	}
+	h.samples[h.next] = d
+	h.next = (h.next + 1) % hedgeSamples
+}
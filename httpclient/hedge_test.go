@@ -0,0 +1,128 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestHedge(t *testing.T) {
+	var calls, cancelled atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Inc()
+		delay := 10 * time.Millisecond
+		if r.URL.Path == "/slow" || n%2 == 1 {
+			delay = 300 * time.Millisecond
+		}
+		select {
+		case <-time.After(delay):
+			w.Write([]byte("ok"))
+		case <-r.Context().Done():
+			cancelled.Inc()
+		}
+	}))
+	defer s.Close()
+
+	t.Run("hedged request wins", func(t *testing.T) {
+		calls.Store(0)
+		cancelled.Store(0)
+		c, err := httpclient.New(&httpclient.Options{Hedge: &httpclient.HedgeOptions{
+			Delay:    20 * time.Millisecond,
+			MaxRatio: 1,
+		}})
+		assert.NoError(t, err)
+		start := time.Now()
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.True(t, time.Since(start) < 200*time.Millisecond)
+		assert.Equal(t, "true", resp.Header.Get(httpclient.HeaderHedged))
+		assert.Equal(t, int32(2), calls.Load())
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, int32(1), cancelled.Load(), "the loser is cancelled")
+	})
+	t.Run("hedge ratio is capped", func(t *testing.T) {
+		calls.Store(0)
+		c, err := httpclient.New(&httpclient.Options{Hedge: &httpclient.HedgeOptions{
+			Delay:    5 * time.Millisecond,
+			MaxRatio: 0.5,
+		}})
+		assert.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			_, err := c.Get(ctx, s.URL+"/slow", nil)
+			assert.Error(t, err)
+			cancel()
+		}
+		assert.Equal(t, int32(6), calls.Load())
+	})
+	t.Run("post is not hedged", func(t *testing.T) {
+		calls.Store(1)
+		c, err := httpclient.New(&httpclient.Options{Hedge: &httpclient.HedgeOptions{
+			Delay:    time.Millisecond,
+			MaxRatio: 1,
+		}})
+		assert.NoError(t, err)
+		resp, err := c.Post(context.Background(), s.URL, nil, []byte("x"))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestHedgeDelay(t *testing.T) {
+	var calls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Inc()
+		delay := 10 * time.Millisecond
+		if r.URL.Path == "/slow-first" {
+			// the first attempt of each request is slow, the hedged one is not
+			delay = 30 * time.Millisecond
+			if n%2 == 1 {
+				delay = 300 * time.Millisecond
+			}
+		}
+		select {
+		case <-time.After(delay):
+			w.Write([]byte("ok"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer s.Close()
+	get := func(c *httpclient.Requests, path string) {
+		resp, err := c.Get(context.Background(), s.URL+path, nil)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}
+
+	t.Run("zero delay is defaulted", func(t *testing.T) {
+		calls.Store(0)
+		c, err := httpclient.New(&httpclient.Options{Hedge: &httpclient.HedgeOptions{MaxRatio: 1}})
+		assert.NoError(t, err)
+		get(c, "/")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+	t.Run("percentile counts the attempts that lose", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{Hedge: &httpclient.HedgeOptions{
+			Delay:      5 * time.Millisecond,
+			Percentile: 0.5,
+			MaxRatio:   1,
+		}})
+		assert.NoError(t, err)
+		calls.Store(0)
+		for i := 0; i < 20; i++ {
+			get(c, "/slow-first")
+		}
+		// the first attempts took at least 35ms, a 10ms request is not hedged any more
+		calls.Store(0)
+		get(c, "/")
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
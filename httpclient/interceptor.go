@@ -0,0 +1,96 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"os"
+)
+
+// RoundTripFunc adapts a function to http.RoundTripper
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Interceptor wraps the next round tripper of the chain. It may mutate the request,
+// inspect or replace the response, or return without calling next at all
+type Interceptor func(next http.RoundTripper) http.RoundTripper
+
+// DefaultInterceptors returns the built-in chain used when Options.Interceptors is nil:
+// signing, debug dump and gzip decoding, outermost first
+func DefaultInterceptors(sign func(*http.Request) error) []Interceptor {
+	return []Interceptor{
+		SignInterceptor(sign),
+		DebugInterceptor(),
+		DecompressInterceptor(),
+	}
+}
+
+// chain builds the round tripper running interceptors in order before rt
+func chain(rt http.RoundTripper, interceptors []Interceptor) http.RoundTripper {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		rt = interceptors[i](rt)
+	}
+	return rt
+}
+
+// SignInterceptor signs every request with sign,
+// if sign is nil the global SignRequest is used when it is set
+func SignInterceptor(sign func(*http.Request) error) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			f := sign
+			if f == nil {
+				f = SignRequest
+			}
+			if f != nil {
+				if err := f(req); err != nil {
+					return nil, errors.New("Add auth info failed, err: " + err.Error())
+				}
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// DebugInterceptor logs requests and responses to stdout when env HTTP_DEBUG is 1,
+// credentials are redacted by the default rules of LogInterceptor
+func DebugInterceptor() Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		logged := LogInterceptor(LogOptions{Logger: debugLogger, Curl: true})(next)
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if os.Getenv("HTTP_DEBUG") != "1" {
+				return next.RoundTrip(req)
+			}
+			return logged.RoundTrip(req)
+		})
+	}
+}
+
+// debugBodyLimit is the default number of body bytes logged,
+// the rest of the body is streamed to the caller without buffering
+const debugBodyLimit = 4096
+
+func debugBody(head []byte, truncated bool) string {
+	if truncated {
+		return string(head) + "...(truncated)"
+	}
+	return string(head)
+}
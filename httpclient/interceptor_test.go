@@ -0,0 +1,92 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterceptors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		gw.Write([]byte("hello"))
+		gw.Close()
+	}))
+	defer s.Close()
+	sign := func(req *http.Request) error {
+		req.Header.Set("Authorization", "token")
+		return nil
+	}
+
+	t.Run("default chain signs and decompresses", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{SignRequest: sign, Compressed: true})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "token", resp.Header.Get("X-Auth"))
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(b))
+	})
+	t.Run("custom order and interceptor", func(t *testing.T) {
+		var order []string
+		trace := func(name string) httpclient.Interceptor {
+			return func(next http.RoundTripper) http.RoundTripper {
+				return httpclient.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+					order = append(order, name)
+					return next.RoundTrip(req)
+				})
+			}
+		}
+		c, err := httpclient.New(&httpclient.Options{Compressed: true, Interceptors: []httpclient.Interceptor{
+			trace("a"), httpclient.SignInterceptor(sign), trace("b"),
+		}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, []string{"a", "b"}, order)
+		assert.Equal(t, "token", resp.Header.Get("X-Auth"))
+		// decompression is not in the chain, the raw gzip body is returned
+		b, _ := ioutil.ReadAll(resp.Body)
+		assert.NotEqual(t, "hello", string(b))
+	})
+	t.Run("short circuit", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{Interceptors: []httpclient.Interceptor{
+			func(next http.RoundTripper) http.RoundTripper {
+				return httpclient.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+					return &http.Response{
+						StatusCode: http.StatusTeapot,
+						Header:     http.Header{},
+						Body:       ioutil.NopCloser(strings.NewReader("cached")),
+						Request:    req,
+					}, nil
+				})
+			},
+		}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), "http://127.0.0.1:1/unreachable", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	})
+	t.Run("sign failure", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{SignRequest: func(*http.Request) error {
+			return errors.New("no key")
+		}})
+		assert.NoError(t, err)
+		_, err = c.Post(context.Background(), s.URL, nil, bytes.Repeat([]byte("a"), 10))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,196 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LimitKind tells which limit a request exceeded
+type LimitKind string
+
+const (
+	// LimitRate is the token bucket of a host
+	LimitRate LimitKind = "rate"
+	// LimitInFlight is the max in flight requests of a host
+	LimitInFlight LimitKind = "in-flight"
+)
+
+// LimitError is returned when a limit is reached and LimitOptions.FailFast is set
+type LimitError struct {
+	Host string
+	Kind LimitKind
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("httpclient: %s limit of host %s exceeded", e.Kind, e.Host)
+}
+
+// LimitOptions limits the requests sent to every host
+type LimitOptions struct {
+	// Rate 每个host每秒允许的请求数，0表示不限速
+	Rate float64
+	// Burst 令牌桶容量，默认为Rate向上取整且至少为1
+	Burst int
+	// MaxInFlight 每个host同时进行的最大请求数，0表示不限制；
+	// 请求在响应body关闭后才结束
+	MaxInFlight int
+	// FailFast returns *LimitError instead of waiting when a limit is reached
+	FailFast bool
+	// OnWait reports how long a request waited for the limiter, it is not called without waiting
+	OnWait func(req *http.Request, wait time.Duration)
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type hostLimit struct {
+	bucket   tokenBucket
+	inFlight chan struct{}
+}
+
+// Limiter applies LimitOptions per host
+type Limiter struct {
+	mu    sync.Mutex
+	opts  LimitOptions
+	hosts map[string]*hostLimit
+}
+
+// NewLimiter creates a limiter, it can be shared by several clients
+func NewLimiter(opts LimitOptions) *Limiter {
+	if opts.Burst <= 0 {
+		opts.Burst = int(math.Max(1, math.Ceil(opts.Rate)))
+	}
+	return &Limiter{opts: opts, hosts: make(map[string]*hostLimit)}
+}
+
+func (l *Limiter) host(host string) *hostLimit {
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostLimit{bucket: tokenBucket{tokens: float64(l.opts.Burst), last: time.Now()}}
+		if l.opts.MaxInFlight > 0 {
+			h.inFlight = make(chan struct{}, l.opts.MaxInFlight)
+		}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// reserve takes a token of host, it returns how long to wait for the token
+func (l *Limiter) reserve(host string) (*hostLimit, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.host(host)
+	if l.opts.Rate <= 0 {
+		return h, 0, nil
+	}
+	now := time.Now()
+	b := &h.bucket
+	b.tokens = math.Min(float64(l.opts.Burst), b.tokens+now.Sub(b.last).Seconds()*l.opts.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return h, 0, nil
+	}
+	if l.opts.FailFast {
+		return h, 0, &LimitError{Host: host, Kind: LimitRate}
+	}
+	wait := time.Duration((1 - b.tokens) / l.opts.Rate * float64(time.Second))
+	// the token is reserved now, later requests wait behind this one
+	b.tokens--
+	return h, wait, nil
+}
+
+// cancel gives back the token reserved by a request which is not sent
+func (l *Limiter) cancel(h *hostLimit) {
+	if l.opts.Rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	h.bucket.tokens++
+	l.mu.Unlock()
+}
+
+// Wait blocks until req is allowed to be sent, it returns the time waited, zero if req
+// did not wait, and a release function to call when the request is done
+func (l *Limiter) Wait(req *http.Request) (time.Duration, func(), error) {
+	start := time.Now()
+	var waited time.Duration
+	ctx := req.Context()
+	h, wait, err := l.reserve(req.URL.Host)
+	if err != nil {
+		return 0, nil, err
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.cancel(h)
+			return time.Since(start), nil, ctx.Err()
+		}
+		waited = time.Since(start)
+	}
+	if h.inFlight == nil {
+		return waited, func() {}, nil
+	}
+	release := func() { <-h.inFlight }
+	select {
+	case h.inFlight <- struct{}{}:
+		return waited, release, nil
+	default:
+	}
+	if l.opts.FailFast {
+		l.cancel(h)
+		return waited, nil, &LimitError{Host: req.URL.Host, Kind: LimitInFlight}
+	}
+	select {
+	case h.inFlight <- struct{}{}:
+		return time.Since(start), release, nil
+	case <-ctx.Done():
+		l.cancel(h)
+		return time.Since(start), nil, ctx.Err()
+	}
+}
+
+// LimitInterceptor waits for l before sending each request
+func LimitInterceptor(l *Limiter) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			waited, release, err := l.Wait(req)
+			if waited > 0 && l.opts.OnWait != nil {
+				l.opts.OnWait(req, waited)
+			}
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				release()
+				return nil, err
+			}
+			resp.Body = &closeHookBody{ReadCloser: resp.Body, done: release}
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestLimit(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Inc()
+		defer inFlight.Dec()
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CAS(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer s.Close()
+
+	t.Run("rate", func(t *testing.T) {
+		var waits atomic.Int32
+		c, err := httpclient.New(&httpclient.Options{Limit: &httpclient.LimitOptions{
+			Rate:   50,
+			Burst:  1,
+			OnWait: func(req *http.Request, d time.Duration) { waits.Inc() },
+		}})
+		assert.NoError(t, err)
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			resp, err := c.Get(context.Background(), s.URL, nil)
+			assert.NoError(t, err)
+			resp.Body.Close()
+		}
+		// the first request takes the burst, each of the others waits at most 20ms
+		assert.True(t, time.Since(start) >= 60*time.Millisecond)
+		assert.True(t, waits.Load() <= 3)
+	})
+	t.Run("rate fail fast", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{Limit: &httpclient.LimitOptions{Rate: 1, FailFast: true}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		_, err = c.Get(context.Background(), s.URL, nil)
+		var le *httpclient.LimitError
+		assert.True(t, errors.As(err, &le))
+		assert.Equal(t, httpclient.LimitRate, le.Kind)
+	})
+	t.Run("wait respects context", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{Limit: &httpclient.LimitOptions{Rate: 0.1}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err = c.Get(ctx, s.URL, nil)
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+	t.Run("max in flight", func(t *testing.T) {
+		maxInFlight.Store(0)
+		var waited atomic.Int64
+		c, err := httpclient.New(&httpclient.Options{Limit: &httpclient.LimitOptions{
+			MaxInFlight: 2,
+			OnWait:      func(req *http.Request, d time.Duration) { waited.Add(int64(d)) },
+		}})
+		assert.NoError(t, err)
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := c.Get(context.Background(), s.URL, nil)
+				if assert.NoError(t, err) {
+					resp.Body.Close()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(2), maxInFlight.Load())
+		assert.True(t, waited.Load() > 0)
+	})
+	t.Run("max in flight fail fast", func(t *testing.T) {
+		c, err := httpclient.New(&httpclient.Options{Limit: &httpclient.LimitOptions{MaxInFlight: 1, FailFast: true}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		// the body is not closed yet, the request still counts
+		_, err = c.Get(context.Background(), s.URL, nil)
+		var le *httpclient.LimitError
+		assert.True(t, errors.As(err, &le))
+		assert.Equal(t, httpclient.LimitInFlight, le.Kind)
+		resp.Body.Close()
+		resp, err = c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	})
+	t.Run("requests not sent give the rate token back", func(t *testing.T) {
+		newReq := func(ctx context.Context) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, s.URL, nil)
+			return req.WithContext(ctx)
+		}
+		l := httpclient.NewLimiter(httpclient.LimitOptions{Rate: 0.1, Burst: 2, MaxInFlight: 1, FailFast: true})
+		_, release, err := l.Wait(newReq(context.Background()))
+		assert.NoError(t, err)
+		_, _, err = l.Wait(newReq(context.Background()))
+		var le *httpclient.LimitError
+		assert.True(t, errors.As(err, &le))
+		assert.Equal(t, httpclient.LimitInFlight, le.Kind)
+		release()
+		_, release, err = l.Wait(newReq(context.Background()))
+		assert.NoError(t, err)
+		release()
+
+		l = httpclient.NewLimiter(httpclient.LimitOptions{Rate: 0.1, Burst: 2, MaxInFlight: 1})
+		_, release, err = l.Wait(newReq(context.Background()))
+		assert.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, _, err = l.Wait(newReq(ctx))
+		assert.Equal(t, context.DeadlineExceeded, err)
+		release()
+		waited, release, err := l.Wait(newReq(context.Background()))
+		assert.NoError(t, err)
+		assert.Equal(t, time.Duration(0), waited)
+		release()
+	})
+}
@@ -0,0 +1,270 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	}
+	return "Level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Field is a key value pair of a structured log entry
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger receives one entry per request, implementations decide which levels to keep
+type Logger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+// redacted replaces sensitive values in logs
+const redacted = "******"
+
+// DefaultRedactHeaders are the headers whose values are never logged
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Auth-Token"}
+
+// DefaultRedactFields are the JSON fields whose values are never logged
+var DefaultRedactFields = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token"}
+
+// LogOptions configures LogInterceptor
+type LogOptions struct {
+	Logger Logger
+	// RedactHeaders 需要隐藏值的请求头与响应头，为空时使用DefaultRedactHeaders
+	RedactHeaders []string
+	// RedactFields 需要隐藏值的JSON字段名，不区分大小写，为空时使用DefaultRedactFields
+	RedactFields []string
+	// MaxBodySize 记录的body最大字节数，0使用默认值4096，负数不记录body
+	MaxBodySize int
+	// Curl 为true时在日志中附带可复现请求的curl命令
+	Curl bool
+}
+
+type redactor struct {
+	headers map[string]bool
+	fields  *regexp.Regexp
+}
+
+func newRedactor(headers, fields []string) *redactor {
+	r := &redactor{headers: make(map[string]bool, len(headers))}
+	for _, h := range headers {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	if len(fields) > 0 {
+		quoted := make([]string, len(fields))
+		for i, f := range fields {
+			quoted[i] = regexp.QuoteMeta(f)
+		}
+		// "field": "value" or "field": 123, the value may be cut by the body size limit
+		r.fields = regexp.MustCompile(`(?i)("(?:` + strings.Join(quoted, "|") + `)"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+	}
+	return r
+}
+
+func (r *redactor) header(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, v := range h {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			out[k] = redacted
+			continue
+		}
+		out[k] = strings.Join(v, ", ")
+	}
+	return out
+}
+
+func (r *redactor) body(b []byte) string {
+	if r.fields == nil {
+		return string(b)
+	}
+	return r.fields.ReplaceAllString(string(b), `${1}"`+redacted+`"`)
+}
+
+// LogInterceptor logs every request with its response, timing and redacted headers and bodies.
+// Successful requests are logged at LevelDebug, error responses at LevelWarn
+// and failed requests at LevelError
+func LogInterceptor(opts LogOptions) Interceptor {
+	if opts.RedactHeaders == nil {
+		opts.RedactHeaders = DefaultRedactHeaders
+	}
+	if opts.RedactFields == nil {
+		opts.RedactFields = DefaultRedactFields
+	}
+	if opts.MaxBodySize == 0 {
+		opts.MaxBodySize = debugBodyLimit
+	}
+	rd := newRedactor(opts.RedactHeaders, opts.RedactFields)
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if opts.Logger == nil {
+				return next.RoundTrip(req)
+			}
+			reqBody := ""
+			if opts.MaxBodySize > 0 {
+				reqBody = "[streamed body]"
+				if req.GetBody != nil {
+					if rc, err := req.GetBody(); err == nil {
+						head, _, truncated, _ := peekBody(rc, int64(opts.MaxBodySize))
+						rc.Close()
+						reqBody = debugBody([]byte(rd.body(head)), truncated)
+					}
+				}
+			}
+			fields := []Field{
+				{Key: "method", Value: req.Method},
+				{Key: "url", Value: req.URL.String()},
+				{Key: "request_headers", Value: rd.header(req.Header)},
+			}
+			if opts.MaxBodySize > 0 && req.Body != nil && req.Body != http.NoBody {
+				fields = append(fields, Field{Key: "request_body", Value: reqBody})
+			}
+			if opts.Curl {
+				fields = append(fields, Field{Key: "curl", Value: curlCommand(req, rd, reqBody)})
+			}
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			fields = append(fields, Field{Key: "duration", Value: time.Since(start)})
+			if err != nil {
+				fields = append(fields, Field{Key: "error", Value: err.Error()})
+				opts.Logger.Log(LevelError, "http request failed", fields...)
+				return nil, err
+			}
+			fields = append(fields,
+				Field{Key: "status", Value: resp.StatusCode},
+				Field{Key: "response_headers", Value: rd.header(resp.Header)})
+			// an event stream stays open, peeking at it would block the caller
+			if opts.MaxBodySize > 0 && mediaType(resp.Header.Get("Content-Type")) != ContentTypeEventStream {
+				head, body, truncated, err := peekBody(resp.Body, int64(opts.MaxBodySize))
+				resp.Body = body
+				if err != nil {
+					resp.Body.Close()
+					return nil, err
+				}
+				fields = append(fields, Field{Key: "response_body", Value: debugBody([]byte(rd.body(head)), truncated)})
+			}
+			level := LevelDebug
+			if resp.StatusCode >= 400 {
+				level = LevelWarn
+			}
+			opts.Logger.Log(level, "http request", fields...)
+			return resp, nil
+		})
+	}
+}
+
+// curlCommand formats req as a curl command, sensitive values are redacted
+func curlCommand(req *http.Request, rd *redactor, body string) string {
+	var b strings.Builder
+	b.WriteString("curl -X " + req.Method + " " + shellQuote(req.URL.String()))
+	headers := rd.header(req.Header)
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteString(" -H " + shellQuote(k+": "+headers[k]))
+	}
+	if body != "" && req.Body != nil && req.Body != http.NoBody {
+		b.WriteString(" --data-binary " + shellQuote(body))
+	}
+	return b.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+type stdLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	min Level
+}
+
+// NewStdLogger returns a Logger writing entries not below min to w, one line per entry
+func NewStdLogger(w io.Writer, min Level) Logger {
+	return &stdLogger{w: w, min: min}
+}
+
+func (l *stdLogger) Log(level Level, msg string, fields ...Field) {
+	if level < l.min {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(time.Now().Format(time.RFC3339) + " " + level.String() + " " + msg)
+	for _, f := range fields {
+		b.WriteString(" " + f.Key + "=")
+		switch v := f.Value.(type) {
+		case string:
+			b.WriteString(strconv.Quote(v))
+		case map[string]string:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			b.WriteString("{")
+			for i, k := range keys {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				b.WriteString(k + ": " + strconv.Quote(v[k]))
+			}
+			b.WriteString("}")
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	b.WriteString("\n")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.w, b.String())
+}
+
+// debugLogger logs to stdout, used by DebugInterceptor
+var debugLogger = NewStdLogger(os.Stdout, LevelDebug)
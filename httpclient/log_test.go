@@ -0,0 +1,118 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+type entry struct {
+	level  httpclient.Level
+	msg    string
+	fields map[string]interface{}
+}
+
+type recordLogger struct {
+	entries []entry
+}
+
+func (l *recordLogger) Log(level httpclient.Level, msg string, fields ...httpclient.Field) {
+	e := entry{level: level, msg: msg, fields: make(map[string]interface{})}
+	for _, f := range fields {
+		e.fields[f.Key] = f.Value
+	}
+	l.entries = append(l.entries, e)
+}
+
+func TestLogInterceptor(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=abc")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(`{"name":"foo","token":"t0ken","items":[` + strings.Repeat(`1,`, 100) + `1]}`))
+	}))
+	defer s.Close()
+	logger := &recordLogger{}
+	c, err := httpclient.New(&httpclient.Options{Log: &httpclient.LogOptions{
+		Logger:      logger,
+		MaxBodySize: 64,
+		Curl:        true,
+	}})
+	assert.NoError(t, err)
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer secret")
+	resp, err := c.Post(context.Background(), s.URL, h, []byte(`{"user":"u","Password": "p@ss"}`))
+	assert.NoError(t, err)
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(b), "t0ken")
+
+	assert.Len(t, logger.entries, 1)
+	e := logger.entries[0]
+	assert.Equal(t, httpclient.LevelDebug, e.level)
+	assert.Equal(t, 200, e.fields["status"])
+	assert.Contains(t, e.fields, "duration")
+	assert.Equal(t, "******", e.fields["request_headers"].(map[string]string)["Authorization"])
+	assert.Equal(t, "******", e.fields["response_headers"].(map[string]string)["Set-Cookie"])
+	assert.Equal(t, `{"user":"u","Password": "******"}`, e.fields["request_body"])
+	respBody := e.fields["response_body"].(string)
+	assert.NotContains(t, respBody, "t0ken")
+	assert.True(t, strings.HasSuffix(respBody, "...(truncated)"))
+	curl := e.fields["curl"].(string)
+	assert.Contains(t, curl, "curl -X POST '"+s.URL+"'")
+	assert.Contains(t, curl, "-H 'Authorization: ******'")
+	assert.NotContains(t, curl, "p@ss")
+
+	_, err = c.Get(context.Background(), s.URL+"/missing", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, httpclient.LevelWarn, logger.entries[1].level)
+	_, err = c.Get(context.Background(), "http://127.0.0.1:1", nil)
+	assert.Error(t, err)
+	assert.Equal(t, httpclient.LevelError, logger.entries[2].level)
+}
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := httpclient.NewStdLogger(&buf, httpclient.LevelInfo)
+	l.Log(httpclient.LevelDebug, "hidden")
+	l.Log(httpclient.LevelWarn, "shown", httpclient.Field{Key: "status", Value: 503},
+		httpclient.Field{Key: "headers", Value: map[string]string{"B": "2", "A": "1"}})
+	out := buf.String()
+	assert.NotContains(t, out, "hidden")
+	assert.Contains(t, out, `WARN shown status=503 headers={A: "1", B: "2"}`)
+}
+
+func TestLogInterceptorEventStream(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpclient.ContentTypeEventStream)
+		w.Write([]byte("data: x\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	logger := &recordLogger{}
+	c, err := httpclient.New(&httpclient.Options{Log: &httpclient.LogOptions{Logger: logger, MaxBodySize: 64}})
+	assert.NoError(t, err)
+
+	// the open stream must reach the caller instead of being peeked at
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	select {
+	case ev := <-c.Subscribe(ctx, s.URL, nil, httpclient.SSEOptions{}):
+		assert.Equal(t, "x", ev.Data)
+	case <-time.After(time.Second):
+		t.Fatal("event stream blocked by logging")
+	}
+	assert.Len(t, logger.entries, 1)
+	assert.NotContains(t, logger.entries[0].fields, "response_body")
+}
@@ -21,6 +21,8 @@ import (
 	"crypto/tls"
 	"net/http"
 	"time"
+
+	"github.com/go-chassis/foundation/tlsutil"
 )
 
 //DefaultOptions is a struct object which has default client option
@@ -30,11 +32,11 @@ var DefaultOptions = Options{
 	ResponseHeaderTimeout: 60 * time.Second,
 	RequestTimeout:        60 * time.Second,
 	ConnsPerHost:          5,
-	TLSVersion:            tls.VersionTLS13,
 }
 
 //Options is a struct which provides options for client
 type Options struct {
+	// TLSVersion 最低TLS版本，0表示使用crypto/tls的默认值
 	TLSVersion            int
 	TLSConfig             *tls.Config
 	Compressed            bool
@@ -43,4 +45,33 @@ type Options struct {
 	RequestTimeout        time.Duration
 	ConnsPerHost          int
 	SignRequest           func(*http.Request) error
+	// Signer signs every request, it takes precedence over SignRequest
+	Signer Signer
+	// TLSOptions builds the TLS config with tlsutil.GetClientTLSConfig when TLSConfig is nil,
+	// note that tlsutil only verifies the server with tlsutil.WithVerifyPeer(true)
+	TLSOptions []tlsutil.TLSOption
+	// Transport configures proxy, dialing and connection pooling
+	Transport TransportOptions
+	// Interceptors wrap every request sent by the client, outermost first.
+	// Nil uses DefaultInterceptors with Signer or SignRequest, which are ignored otherwise
+	Interceptors []Interceptor
+	// Retry enables retrying failed requests, the retry interceptor runs
+	// outside of Interceptors so that each attempt is signed again
+	Retry *RetryOptions
+	// Endpoints resolves relative urls such as "/v4/default/registry/microservices"
+	// against a balanced set of base urls
+	Endpoints *EndpointOptions
+	// Log logs every request in place of DebugInterceptor in the default interceptors
+	Log *LogOptions
+	// Trace records the timing of every attempt of a request
+	Trace *TraceOptions
+	// Limit caps the rate and the concurrency of requests per host
+	Limit *LimitOptions
+	// Cache caches GET responses, it runs after Interceptors so that it sees the credentials they add,
+	// cache hits still pass Retry, Hedge, Endpoints and Limit
+	Cache *CacheOptions
+	// Hedge sends a second attempt of slow GET requests, each retry attempt is hedged
+	Hedge *HedgeOptions
+	// Compression compresses request bodies, signing interceptors see the compressed body
+	Compression *CompressionOptions
 }
@@ -0,0 +1,186 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+)
+
+// DefaultIdempotencyKeyHeader marks a non idempotent request as safe to retry
+const DefaultIdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultRetryStatusCodes are the response status codes retried by default
+var DefaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryOptions configures retrying of failed requests.
+// Only idempotent methods are retried, unless the request has the IdempotencyKeyHeader header
+type RetryOptions struct {
+	// MaxAttempts 最大请求次数，包括第一次请求，默认3
+	MaxAttempts int
+	// StatusCodes 需要重试的响应码，为空时使用DefaultRetryStatusCodes
+	StatusCodes []int
+	// Backoff 计算重试前的等待时间，为空时使用backoff.GetBackoff()；
+	// 响应带有Retry-After头时以其为准
+	Backoff backoff.Backoff
+	// IdempotencyKeyHeader 为空时使用DefaultIdempotencyKeyHeader
+	IdempotencyKeyHeader string
+	// RetryOn 判断请求错误是否需要重试，为空时使用IsConnectionError
+	RetryOn func(err error) bool
+}
+
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 3
+	}
+	if len(o.StatusCodes) == 0 {
+		o.StatusCodes = DefaultRetryStatusCodes
+	}
+	if o.Backoff == nil {
+		o.Backoff = backoff.GetBackoff()
+	}
+	if o.IdempotencyKeyHeader == "" {
+		o.IdempotencyKeyHeader = DefaultIdempotencyKeyHeader
+	}
+	if o.RetryOn == nil {
+		o.RetryOn = IsConnectionError
+	}
+	return o
+}
+
+// IsConnectionError reports whether err is a failure of the connection,
+// such as a refused dial, a reset or a connection closed by the server.
+// Certificate errors, redirect policy errors and client timeouts are not.
+func IsConnectionError(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var oe *net.OpError
+	return errors.As(err, &oe)
+}
+
+// RetryInterceptor retries requests failed with errors accepted by RetryOn or retryable status codes,
+// the request body is replayed from req.GetBody and every attempt goes through the rest of the chain
+func RetryInterceptor(opts RetryOptions) Interceptor {
+	o := opts.withDefaults()
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if !o.retryable(req) {
+				return next.RoundTrip(req)
+			}
+			ctx := req.Context()
+			for attempt := 1; ; attempt++ {
+				r, err := cloneRequest(req)
+				if err != nil {
+					return nil, err
+				}
+				resp, err := next.RoundTrip(r)
+				if attempt >= o.MaxAttempts || ctx.Err() != nil {
+					return resp, err
+				}
+				delay := o.Backoff.Delay(attempt - 1)
+				if err != nil {
+					if !o.RetryOn(err) {
+						return nil, err
+					}
+				} else {
+					if !o.retryStatus(resp.StatusCode) {
+						return resp, nil
+					}
+					if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+						delay = d
+					}
+					_, _ = io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				}
+			}
+		})
+	}
+}
+
+// retryable reports whether req is safe to send more than once
+func (o RetryOptions) retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// the body can not be replayed
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get(o.IdempotencyKeyHeader) != ""
+}
+
+func (o RetryOptions) retryStatus(code int) bool {
+	for _, c := range o.StatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneRequest copies req with a fresh body, so that interceptors of one attempt
+// do not affect the next one
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
+
+// parseRetryAfter parses the Retry-After header, in seconds or as a http date
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,111 @@
+package httpclient_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	var bodies []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if calls.Inc()%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+	retry := &httpclient.RetryOptions{
+		MaxAttempts: 3,
+		Backoff:     &backoff.PowerBackoff{InitDelay: time.Millisecond, MaxDelay: time.Millisecond, Factor: 1},
+	}
+	c, err := httpclient.New(&httpclient.Options{Retry: retry})
+	assert.NoError(t, err)
+
+	t.Run("retry idempotent method and replay body", func(t *testing.T) {
+		calls.Store(0)
+		bodies = nil
+		resp, err := c.Put(context.Background(), s.URL, nil, []byte("data"))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Equal(t, []string{"data", "data", "data"}, bodies)
+	})
+	t.Run("post is not retried", func(t *testing.T) {
+		calls.Store(0)
+		resp, err := c.Post(context.Background(), s.URL, nil, []byte("data"))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+	t.Run("post with idempotency key", func(t *testing.T) {
+		calls.Store(0)
+		h := http.Header{}
+		h.Set(httpclient.DefaultIdempotencyKeyHeader, "abc")
+		resp, err := c.Post(context.Background(), s.URL, h, []byte("data"))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+	t.Run("attempts exhausted", func(t *testing.T) {
+		calls.Store(0)
+		c, err := httpclient.New(&httpclient.Options{Retry: &httpclient.RetryOptions{
+			MaxAttempts: 2,
+			Backoff:     retry.Backoff,
+		}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+	t.Run("connection error", func(t *testing.T) {
+		var attempts atomic.Int32
+		c, err := httpclient.New(&httpclient.Options{Retry: retry, Interceptors: []httpclient.Interceptor{
+			func(next http.RoundTripper) http.RoundTripper {
+				return httpclient.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+					attempts.Inc()
+					return next.RoundTrip(req)
+				})
+			},
+		}})
+		assert.NoError(t, err)
+		_, err = c.Get(context.Background(), "http://127.0.0.1:1", nil)
+		assert.Error(t, err)
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+	t.Run("certificate error is not retried", func(t *testing.T) {
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		ts.StartTLS()
+		defer ts.Close()
+		var attempts atomic.Int32
+		c, err := httpclient.New(&httpclient.Options{Retry: retry, Interceptors: []httpclient.Interceptor{
+			func(next http.RoundTripper) http.RoundTripper {
+				return httpclient.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+					attempts.Inc()
+					return next.RoundTrip(req)
+				})
+			},
+		}})
+		assert.NoError(t, err)
+		_, err = c.Get(context.Background(), ts.URL, nil)
+		assert.Error(t, err)
+		assert.False(t, httpclient.IsConnectionError(err))
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+}
@@ -0,0 +1,316 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signer adds credentials to a request, implementations must be safe for concurrent use
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// SignerFunc adapts a function such as Options.SignRequest to Signer
+type SignerFunc func(req *http.Request) error
+
+// Sign calls f(req)
+func (f SignerFunc) Sign(req *http.Request) error {
+	return f(req)
+}
+
+// ChainSigners returns a signer running signers in order, it stops at the first error
+func ChainSigners(signers ...Signer) Signer {
+	return SignerFunc(func(req *http.Request) error {
+		for _, s := range signers {
+			if err := s.Sign(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BearerToken sets a static bearer token
+func BearerToken(token string) Signer {
+	return SignerFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// BasicAuth sets the basic authentication header
+func BasicAuth(username, password string) Signer {
+	return SignerFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// Token is an access token, a zero Expiry means the token never expires
+type Token struct {
+	AccessToken string
+	Expiry      time.Time
+}
+
+// TokenSource fetches a new token
+type TokenSource func(ctx context.Context) (*Token, error)
+
+type refreshingSigner struct {
+	mu          sync.Mutex
+	source      TokenSource
+	earlyExpiry time.Duration
+	token       *Token
+}
+
+// RefreshingBearer sets a bearer token fetched from source. The token is cached
+// and fetched again earlyExpiry before it expires, concurrent requests share one fetch
+func RefreshingBearer(source TokenSource, earlyExpiry time.Duration) Signer {
+	return &refreshingSigner{source: source, earlyExpiry: earlyExpiry}
+}
+
+func (s *refreshingSigner) Sign(req *http.Request) error {
+	token, err := s.get(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return nil
+}
+
+func (s *refreshingSigner) get(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != nil && (s.token.Expiry.IsZero() || time.Now().Add(s.earlyExpiry).Before(s.token.Expiry)) {
+		return s.token, nil
+	}
+	token, err := s.source(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("httpclient: fetch token failed: %w", err)
+	}
+	if token == nil || token.AccessToken == "" {
+		return nil, errors.New("httpclient: token source returned an empty token")
+	}
+	s.token = token
+	return token, nil
+}
+
+const (
+	// HMACAlgorithm is the algorithm name in the Authorization header set by HMACSigner
+	HMACAlgorithm = "SDK-HMAC-SHA256"
+	// HeaderSDKDate is the signing time header set by HMACSigner
+	HeaderSDKDate = "X-Sdk-Date"
+	// HeaderSDKContentSHA256 is the signed header carrying the SHA256 of the body,
+	// or UNSIGNED-PAYLOAD for a streamed body which can not be read twice
+	HeaderSDKContentSHA256 = "X-Sdk-Content-Sha256"
+	sdkDateFormat          = "20060102T150405Z"
+	unsignedPayload        = "UNSIGNED-PAYLOAD"
+)
+
+// ErrInvalidSignature is returned by HMACSigner.Verify
+var ErrInvalidSignature = errors.New("httpclient: invalid signature")
+
+// HMACSigner signs requests with an AK/SK pair. The signature is the HMAC-SHA256 of a canonical
+// request made of the method, path, sorted query, signed headers and the SHA256 of the body:
+//
+//	Authorization: SDK-HMAC-SHA256 Access=AK, SignedHeaders=host;x-sdk-content-sha256;x-sdk-date, Signature=...
+//
+// A body without GetBody is streamed and not hashed, X-Sdk-Content-Sha256 is UNSIGNED-PAYLOAD then.
+type HMACSigner struct {
+	AccessKey string
+	SecretKey string
+	// SignedHeaders 额外参与签名的请求头，host、x-sdk-content-sha256与x-sdk-date总是参与签名
+	SignedHeaders []string
+}
+
+// Sign sets X-Sdk-Date, X-Sdk-Content-Sha256 and Authorization
+func (s *HMACSigner) Sign(req *http.Request) error {
+	bodyHash, err := hashBody(req)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HeaderSDKDate, time.Now().UTC().Format(sdkDateFormat))
+	req.Header.Set(HeaderSDKContentSHA256, bodyHash)
+	signed := s.signedHeaders()
+	sig := s.signature(req, signed)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Access=%s, SignedHeaders=%s, Signature=%s",
+		HMACAlgorithm, s.AccessKey, strings.Join(signed, ";"), sig))
+	return nil
+}
+
+// Verify checks the signature of a request signed by a signer with the same keys,
+// it lets a server or a test verify requests. The body of a server request is buffered
+// and checked against X-Sdk-Content-Sha256, unless it is UNSIGNED-PAYLOAD
+func (s *HMACSigner) Verify(req *http.Request) error {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, HMACAlgorithm+" ") {
+		return ErrInvalidSignature
+	}
+	params := make(map[string]string)
+	for _, kv := range strings.Split(strings.TrimPrefix(auth, HMACAlgorithm+" "), ",") {
+		if i := strings.Index(kv, "="); i > 0 {
+			params[strings.TrimSpace(kv[:i])] = strings.TrimSpace(kv[i+1:])
+		}
+	}
+	if params["Access"] != s.AccessKey {
+		return ErrInvalidSignature
+	}
+	signed := strings.Split(params["SignedHeaders"], ";")
+	if !signs(signed, HeaderSDKContentSHA256) {
+		// the body hash must be covered by the signature
+		return ErrInvalidSignature
+	}
+	sig := s.signature(req, signed)
+	if !hmac.Equal([]byte(sig), []byte(params["Signature"])) {
+		return ErrInvalidSignature
+	}
+	claimed := req.Header.Get(HeaderSDKContentSHA256)
+	if claimed == unsignedPayload {
+		return nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// a server request, buffer the body to hash it and keep it readable
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	bodyHash, err := hashBody(req)
+	if err != nil {
+		return err
+	}
+	if bodyHash != claimed {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *HMACSigner) signedHeaders() []string {
+	signed := []string{"host", strings.ToLower(HeaderSDKDate), strings.ToLower(HeaderSDKContentSHA256)}
+	for _, h := range s.SignedHeaders {
+		signed = append(signed, strings.ToLower(h))
+	}
+	sort.Strings(signed)
+	return signed
+}
+
+// signs reports whether header is one of the signed headers
+func signs(signed []string, header string) bool {
+	for _, name := range signed {
+		if strings.EqualFold(name, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// signature signs the body hash in X-Sdk-Content-Sha256, Verify checks the body against it separately
+func (s *HMACSigner) signature(req *http.Request, signed []string) string {
+	date := req.Header.Get(HeaderSDKDate)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders(req, signed),
+		strings.Join(signed, ";"),
+		req.Header.Get(HeaderSDKContentSHA256),
+	}, "\n")
+	h := sha256.Sum256([]byte(canonical))
+	stringToSign := HMACAlgorithm + "\n" + date + "\n" + hex.EncodeToString(h[:])
+	mac := hmac.New(sha256.New, []byte(s.SecretKey))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashBody returns the hex SHA256 of the body, read through GetBody so that the body is untouched,
+// or UNSIGNED-PAYLOAD if the body has no GetBody
+func hashBody(req *http.Request) (string, error) {
+	h := sha256.New()
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return unsignedPayload, nil
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func canonicalPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+func canonicalQuery(u *url.URL) string {
+	q := u.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func canonicalHeaders(req *http.Request, signed []string) string {
+	var b strings.Builder
+	for _, name := range signed {
+		v := req.Header.Get(name)
+		if name == "host" {
+			v = req.Host
+			if v == "" {
+				v = req.URL.Host
+			}
+		}
+		b.WriteString(name + ":" + strings.TrimSpace(v) + "\n")
+	}
+	return b.String()
+}
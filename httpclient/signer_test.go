@@ -0,0 +1,132 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestSigners(t *testing.T) {
+	newReq := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, "http://127.0.0.1/v1/items?b=2&a=1", strings.NewReader("body"))
+		return req
+	}
+
+	t.Run("bearer and basic", func(t *testing.T) {
+		req := newReq()
+		assert.NoError(t, httpclient.BearerToken("abc").Sign(req))
+		assert.Equal(t, "Bearer abc", req.Header.Get("Authorization"))
+		assert.NoError(t, httpclient.BasicAuth("u", "p").Sign(req))
+		u, p, ok := req.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "u", u)
+		assert.Equal(t, "p", p)
+	})
+	t.Run("refreshing token is cached", func(t *testing.T) {
+		var fetched atomic.Int32
+		signer := httpclient.RefreshingBearer(func(ctx context.Context) (*httpclient.Token, error) {
+			n := fetched.Inc()
+			return &httpclient.Token{
+				AccessToken: "t" + string(rune('0'+n)),
+				Expiry:      time.Now().Add(50 * time.Millisecond),
+			}, nil
+		}, 10*time.Millisecond)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := newReq()
+				assert.NoError(t, signer.Sign(req))
+				assert.Equal(t, "Bearer t1", req.Header.Get("Authorization"))
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), fetched.Load())
+		time.Sleep(45 * time.Millisecond)
+		req := newReq()
+		assert.NoError(t, signer.Sign(req))
+		assert.Equal(t, "Bearer t2", req.Header.Get("Authorization"))
+	})
+	t.Run("refreshing token failure", func(t *testing.T) {
+		signer := httpclient.RefreshingBearer(func(ctx context.Context) (*httpclient.Token, error) {
+			return nil, errors.New("unauthorized")
+		}, 0)
+		assert.Error(t, signer.Sign(newReq()))
+	})
+	t.Run("hmac", func(t *testing.T) {
+		signer := &httpclient.HMACSigner{AccessKey: "ak", SecretKey: "sk", SignedHeaders: []string{"Content-Type"}}
+		req := newReq()
+		req.Header.Set("Content-Type", "text/plain")
+		assert.NoError(t, signer.Sign(req))
+		auth := req.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(auth, "SDK-HMAC-SHA256 Access=ak, SignedHeaders=content-type;host;x-sdk-content-sha256;x-sdk-date, Signature="))
+		assert.NotEmpty(t, req.Header.Get(httpclient.HeaderSDKDate))
+		assert.NoError(t, signer.Verify(req))
+
+		tampered := req.Clone(context.Background())
+		tampered.Body = nil
+		tampered.GetBody = nil
+		tampered.Header.Set("Content-Type", "application/json")
+		assert.Equal(t, httpclient.ErrInvalidSignature, signer.Verify(tampered))
+		other := &httpclient.HMACSigner{AccessKey: "ak", SecretKey: "other"}
+		assert.Equal(t, httpclient.ErrInvalidSignature, other.Verify(req))
+
+		tampered = req.Clone(context.Background())
+		tampered.Body = ioutil.NopCloser(strings.NewReader("changed"))
+		tampered.GetBody = nil
+		assert.Equal(t, httpclient.ErrInvalidSignature, signer.Verify(tampered))
+	})
+	t.Run("hmac with a streamed body", func(t *testing.T) {
+		verifier := &httpclient.HMACSigner{AccessKey: "ak", SecretKey: "sk"}
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if verifier.Verify(r) != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.Copy(w, r.Body)
+		}))
+		defer s.Close()
+		c, err := httpclient.New(&httpclient.Options{Signer: &httpclient.HMACSigner{AccessKey: "ak", SecretKey: "sk"}})
+		assert.NoError(t, err)
+		// a reader without a known length has no GetBody
+		resp, err := c.DoStream(context.Background(), http.MethodPost, s.URL, nil, ioutil.NopCloser(strings.NewReader("stream")))
+		assert.NoError(t, err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "stream", string(b))
+	})
+	t.Run("chain through client", func(t *testing.T) {
+		verifier := &httpclient.HMACSigner{AccessKey: "ak", SecretKey: "sk"}
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Tenant") != "default" || verifier.Verify(r) != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		defer s.Close()
+		c, err := httpclient.New(&httpclient.Options{Signer: httpclient.ChainSigners(
+			httpclient.SignerFunc(func(req *http.Request) error {
+				req.Header.Set("X-Tenant", "default")
+				return nil
+			}),
+			&httpclient.HMACSigner{AccessKey: "ak", SecretKey: "sk"},
+		)})
+		assert.NoError(t, err)
+		resp, err := c.Post(context.Background(), s.URL+"/v1/items?x=1", nil, []byte(`{"a":1}`))
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
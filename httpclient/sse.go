@@ -0,0 +1,239 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+)
+
+// ContentTypeEventStream is the content type of server sent events
+const ContentTypeEventStream = "text/event-stream"
+
+// Event is a server sent event
+type Event struct {
+	ID    string
+	Event string // 事件类型，为空表示message
+	Data  string
+	Retry time.Duration // 服务端要求的重连间隔，0表示未指定
+}
+
+// EventReader parses server sent events from a stream
+type EventReader struct {
+	r      *bufio.Reader
+	lastID string
+	retry  time.Duration
+}
+
+// NewEventReader creates a parser reading r
+func NewEventReader(r io.Reader) *EventReader {
+	return &EventReader{r: bufio.NewReader(r)}
+}
+
+// Retry returns the reconnection time last sent by the server, 0 if it sent none
+func (er *EventReader) Retry() time.Duration {
+	return er.retry
+}
+
+// Next returns the next event, it returns io.EOF at the end of the stream.
+// Comments and events without data are skipped, the id and retry of an event
+// are kept for the following events as the specification requires
+func (er *EventReader) Next() (*Event, error) {
+	var (
+		data     strings.Builder
+		hasData  bool
+		ev       = &Event{}
+		idLoaded bool
+	)
+	for {
+		line, err := er.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if hasData {
+				if !idLoaded {
+					ev.ID = er.lastID
+				}
+				ev.Data = data.String()
+				ev.Retry = er.retry
+				return ev, nil
+			}
+			ev = &Event{}
+			idLoaded = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "data":
+			if hasData {
+				data.WriteString("\n")
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			ev.Event = value
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				ev.ID, er.lastID, idLoaded = value, value, true
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				er.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if err == io.EOF {
+			// the stream ended in the middle of an event, which is dropped
+			return nil, io.EOF
+		}
+	}
+}
+
+// ErrStreamClosed is reported when the server answers 204, which asks the client to stop reconnecting
+var ErrStreamClosed = errors.New("httpclient: event stream closed by server")
+
+// SSEOptions configures Subscribe
+type SSEOptions struct {
+	// Backoff 重连等待时间，为空时使用backoff.GetBackoff()；服务端指定retry时以其为准
+	Backoff backoff.Backoff
+	// LastEventID 首次连接时发送的Last-Event-ID
+	LastEventID string
+	// BufferSize 事件channel的缓冲大小
+	BufferSize int
+	// OnError reports connection errors, the client reconnects after each of them
+	OnError func(err error)
+}
+
+// Subscribe connects to an event stream and delivers its events through the returned channel.
+// The client reconnects with Last-Event-ID after the stream breaks, the channel is closed
+// when ctx is done or the server answers 204
+func (r *Requests) Subscribe(ctx context.Context, url string, headers http.Header, opts SSEOptions) <-chan *Event {
+	if opts.Backoff == nil {
+		opts.Backoff = backoff.GetBackoff()
+	}
+	events := make(chan *Event, opts.BufferSize)
+	go func() {
+		defer close(events)
+		client := r.streaming()
+		state := &streamState{lastID: opts.LastEventID}
+		for failures := 0; ; failures++ {
+			connected, err := client.readEvents(ctx, url, headers, state, func(ev *Event) bool {
+				select {
+				case events <- ev:
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			// the server ending the stream is not worth reporting, the client just reconnects
+			if err != nil && err != io.EOF && opts.OnError != nil {
+				opts.OnError(err)
+			}
+			if err == ErrStreamClosed {
+				return
+			}
+			if connected {
+				failures = 0
+			}
+			delay := opts.Backoff.Delay(failures)
+			if state.retry > 0 {
+				delay = state.retry
+			}
+			if !sleep(ctx, delay) {
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// streamState is carried from one connection of an event stream to the next
+type streamState struct {
+	lastID string
+	retry  time.Duration
+}
+
+// readEvents reads one connection of the stream until it breaks or deliver returns false,
+// connected is true if the server accepted the connection
+func (r *Requests) readEvents(ctx context.Context, url string, headers http.Header, state *streamState, deliver func(*Event) bool) (connected bool, err error) {
+	h := make(http.Header, len(headers)+3)
+	for k, v := range headers {
+		h[k] = v
+	}
+	h.Set("Accept", ContentTypeEventStream)
+	h.Set("Cache-Control", "no-cache")
+	if state.lastID != "" {
+		h.Set("Last-Event-ID", state.lastID)
+	}
+	resp, err := r.Get(ctx, url, h)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return true, ErrStreamClosed
+	}
+	if err := CheckResponse(resp); err != nil {
+		return false, err
+	}
+	if t := mediaType(resp.Header.Get("Content-Type")); t != ContentTypeEventStream {
+		return false, fmt.Errorf("httpclient: unexpected content type %s of event stream", t)
+	}
+	er := &EventReader{r: bufio.NewReader(resp.Body), lastID: state.lastID, retry: state.retry}
+	defer func() {
+		state.lastID, state.retry = er.lastID, er.retry
+	}()
+	for {
+		ev, err := er.Next()
+		if err != nil {
+			return true, err
+		}
+		if !deliver(ev) {
+			return true, ctx.Err()
+		}
+	}
+}
+
+// sleep waits for d, it returns false if ctx is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,111 @@
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+var fastBackoff = &backoff.PowerBackoff{InitDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Factor: 1}
+
+func TestEventReader(t *testing.T) {
+	er := httpclient.NewEventReader(strings.NewReader(": comment\r\n" +
+		"event: update\r\n" +
+		"id: 1\r\n" +
+		"data: first\r\n" +
+		"data:second\r\n" +
+		"\r\n" +
+		"retry: 1500\n" +
+		"\n" +
+		"id: bad\x00id\n" +
+		"data\n" +
+		"\n" +
+		"data: dropped"))
+	ev, err := er.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &httpclient.Event{ID: "1", Event: "update", Data: "first\nsecond"}, ev)
+
+	// the retry only block dispatches nothing, the id with NUL is ignored and a bare data field is empty
+	ev, err = er.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &httpclient.Event{ID: "1", Data: "", Retry: 1500 * time.Millisecond}, ev)
+	assert.Equal(t, 1500*time.Millisecond, er.Retry())
+
+	_, err = er.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSubscribe(t *testing.T) {
+	var conns atomic.Int32
+	var lastIDs []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := conns.Inc()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		assert.Equal(t, httpclient.ContentTypeEventStream, r.Header.Get("Accept"))
+		if n == 3 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", httpclient.ContentTypeEventStream)
+		if n == 1 {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			// longer than RequestTimeout, streams must not be cut by it
+			time.Sleep(150 * time.Millisecond)
+			fmt.Fprint(w, "id: 1\ndata: a\n\nid: 2\ndata: b\n\n")
+			return
+		}
+		fmt.Fprint(w, "id: 3\ndata: c\n\n")
+	}))
+	defer s.Close()
+
+	c, err := httpclient.New(&httpclient.Options{RequestTimeout: 100 * time.Millisecond})
+	assert.NoError(t, err)
+	var errs []error
+	events := c.Subscribe(context.Background(), s.URL, nil, httpclient.SSEOptions{
+		Backoff: fastBackoff,
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+	var data []string
+	for ev := range events {
+		data = append(data, ev.Data)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, data)
+	assert.Equal(t, []string{"", "2", "3"}, lastIDs)
+	assert.Equal(t, []error{httpclient.ErrStreamClosed}, errs)
+}
+
+func TestSubscribeCancel(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", httpclient.ContentTypeEventStream)
+		fmt.Fprint(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+
+	c, err := httpclient.New(nil)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	events := c.Subscribe(ctx, s.URL, nil, httpclient.SSEOptions{Backoff: fastBackoff, LastEventID: "7"})
+	ev := <-events
+	assert.Equal(t, "hello", ev.Data)
+	assert.Equal(t, "7", ev.ID)
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
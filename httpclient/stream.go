@@ -0,0 +1,152 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// ProgressFunc reports the bytes transferred so far, total is -1 if it is unknown
+type ProgressFunc func(transferred, total int64)
+
+type progressKey struct{}
+
+type progress struct {
+	upload, download ProgressFunc
+}
+
+// WithProgress returns a context reporting the upload and download progress
+// of the request sent with it, either function can be nil
+func WithProgress(ctx context.Context, upload, download ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress{upload: upload, download: download})
+}
+
+// DoStream sends a request with a streamed body. The content length is set when it is known
+// from the reader or from the Content-Length header, otherwise the body is sent chunked.
+// Only bodies of *bytes.Buffer, *bytes.Reader, *strings.Reader and ReplayableBody can be replayed by retries
+func (r *Requests) DoStream(ctx context.Context, method string, url string, headers http.Header, body io.Reader) (resp *http.Response, err error) {
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	if _, ok := headers["Accept"]; !ok {
+		headers["Accept"] = []string{"*/*"}
+	}
+	if _, ok := headers["Accept-Encoding"]; !ok && r.options.Compressed {
+		headers["Accept-Encoding"] = []string{"deflate, gzip"}
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %s", err.Error())
+	}
+	req = req.WithContext(ctx)
+	req.Header = headers
+	if rb, ok := body.(ReplayableBody); ok {
+		req.GetBody = rb.Reopen
+		req.ContentLength = rb.Size()
+	}
+	if body != nil && req.ContentLength <= 0 && req.GetBody == nil {
+		req.ContentLength = contentLength(body, headers)
+	}
+	p, _ := ctx.Value(progressKey{}).(progress)
+	if p.upload != nil && req.Body != nil && req.Body != http.NoBody {
+		total := req.ContentLength
+		req.Body = newProgressReader(req.Body, total, p.upload)
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				rc, err := getBody()
+				if err != nil {
+					return nil, err
+				}
+				return newProgressReader(rc, total, p.upload), nil
+			}
+		}
+	}
+	resp, err = r.roundTripper().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if p.download != nil {
+		resp.Body = newProgressReader(resp.Body, resp.ContentLength, p.download)
+	}
+	return resp, nil
+}
+
+// contentLength returns the length of a body unknown to http.NewRequest, or -1 to send it chunked
+func contentLength(body io.Reader, headers http.Header) int64 {
+	if v := headers.Get("Content-Length"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			return n
+		}
+	}
+	if f, ok := body.(*os.File); ok {
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			if offset, err := f.Seek(0, io.SeekCurrent); err == nil {
+				return fi.Size() - offset
+			}
+		}
+	}
+	return -1
+}
+
+type progressReader struct {
+	io.ReadCloser
+	transferred int64
+	total       int64
+	report      ProgressFunc
+}
+
+func newProgressReader(rc io.ReadCloser, total int64, report ProgressFunc) *progressReader {
+	if total <= 0 {
+		total = -1
+	}
+	return &progressReader{ReadCloser: rc, total: total, report: report}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.report(r.transferred, r.total)
+	}
+	return n, err
+}
+
+// peekBody reads at most limit bytes of rc and returns them with a reader
+// yielding the whole body again, truncated is true if rc has more bytes
+func peekBody(rc io.ReadCloser, limit int64) (head []byte, body io.ReadCloser, truncated bool, err error) {
+	head, err = ioutil.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, rc, false, err
+	}
+	truncated = int64(len(head)) > limit
+	body = &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(head), rc), Closer: rc}
+	if truncated {
+		head = head[:limit]
+	}
+	return head, body, truncated, nil
+}
+
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
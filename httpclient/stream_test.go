@@ -0,0 +1,88 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+// onlyReader hides the type of a reader from http.NewRequest
+type onlyReader struct{ io.Reader }
+
+func TestDoStream(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 10000)
+	type seen struct {
+		length   int64
+		encoding []string
+		size     int
+	}
+	var last seen
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		last = seen{length: r.ContentLength, encoding: r.TransferEncoding, size: len(b)}
+		w.Write(payload)
+	}))
+	defer s.Close()
+	c, err := httpclient.New(&httpclient.Options{})
+	assert.NoError(t, err)
+
+	t.Run("unknown length is sent chunked", func(t *testing.T) {
+		resp, err := c.DoStream(context.Background(), "PUT", s.URL, nil, onlyReader{bytes.NewReader(payload)})
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, int64(-1), last.length)
+		assert.Equal(t, []string{"chunked"}, last.encoding)
+		assert.Equal(t, len(payload), last.size)
+	})
+	t.Run("length from header", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Content-Length", "100000")
+		resp, err := c.DoStream(context.Background(), "PUT", s.URL, h, onlyReader{bytes.NewReader(payload)})
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, int64(len(payload)), last.length)
+	})
+	t.Run("progress", func(t *testing.T) {
+		var up, down int64
+		var upTotal int64
+		ctx := httpclient.WithProgress(context.Background(),
+			func(n, total int64) { up, upTotal = n, total },
+			func(n, total int64) { down = n })
+		resp, err := c.DoStream(ctx, "POST", s.URL, nil, strings.NewReader(string(payload)))
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, int64(len(payload)), up)
+		assert.Equal(t, int64(len(payload)), upTotal)
+		assert.Equal(t, int64(len(b)), down)
+		assert.Equal(t, len(payload), len(b))
+	})
+	t.Run("debug dump does not consume the body", func(t *testing.T) {
+		// client_test.go turns HTTP_DEBUG on for the whole package, keep it as it was
+		old, ok := os.LookupEnv("HTTP_DEBUG")
+		os.Setenv("HTTP_DEBUG", "1")
+		defer func() {
+			if ok {
+				os.Setenv("HTTP_DEBUG", old)
+			} else {
+				os.Unsetenv("HTTP_DEBUG")
+			}
+		}()
+		resp, err := c.DoStream(context.Background(), "POST", s.URL, nil, onlyReader{bytes.NewReader(payload)})
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, payload, b)
+	})
+}
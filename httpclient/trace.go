@@ -0,0 +1,222 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timing is the trace of one request, durations not involved in the request are zero,
+// e.g. DNS, Connect and TLS of a request sent on a reused connection
+type Timing struct {
+	Host      string
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration // 从发出请求到收到响应第一个字节
+	Total     time.Duration // 从发出请求到响应body读完或关闭
+	Reused    bool          // 是否复用了连接
+}
+
+// TraceOptions configures TraceInterceptor
+type TraceOptions struct {
+	// OnTrace runs when the response body is read to the end or closed, or when the request failed
+	OnTrace func(req *http.Request, t *Timing)
+	// Histograms aggregates the total latency of requests per host, it can be nil
+	Histograms *HostHistograms
+}
+
+type timingKey struct{}
+
+// TimingFrom returns the timing of the request sent for resp,
+// Total is set after the response body is read to the end or closed
+func TimingFrom(resp *http.Response) (*Timing, bool) {
+	if resp == nil || resp.Request == nil {
+		return nil, false
+	}
+	t, ok := resp.Request.Context().Value(timingKey{}).(*Timing)
+	return t, ok
+}
+
+// TraceInterceptor records the timing of every request with net/http/httptrace
+func TraceInterceptor(opts TraceOptions) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			t := &Timing{Host: req.URL.Host}
+			start := time.Now()
+			ctx := context.WithValue(req.Context(), timingKey{}, t)
+			ctx = httptrace.WithClientTrace(ctx, newClientTrace(t, start))
+			resp, err := next.RoundTrip(req.WithContext(ctx))
+			finish := func() {
+				t.Total = time.Since(start)
+				if opts.Histograms != nil {
+					opts.Histograms.Observe(t.Host, t.Total)
+				}
+				if opts.OnTrace != nil {
+					opts.OnTrace(req, t)
+				}
+			}
+			if err != nil {
+				finish()
+				return nil, err
+			}
+			resp.Body = &traceBody{ReadCloser: resp.Body, finish: finish}
+			return resp, nil
+		})
+	}
+}
+
+// newClientTrace fills t, the hooks may run in other goroutines
+func newClientTrace(t *Timing, start time.Time) *httptrace.ClientTrace {
+	var (
+		mu                            sync.Mutex
+		dnsStart, connStart, tlsStart time.Time
+	)
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			dnsStart = time.Now()
+			mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			t.DNS = time.Since(dnsStart)
+			mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			if connStart.IsZero() {
+				connStart = time.Now()
+			}
+			mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			mu.Lock()
+			t.Connect = time.Since(connStart)
+			mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			tlsStart = time.Now()
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			t.TLS = time.Since(tlsStart)
+			mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			t.Reused = info.Reused
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			t.FirstByte = time.Since(start)
+			mu.Unlock()
+		},
+	}
+}
+
+type traceBody struct {
+	io.ReadCloser
+	once   sync.Once
+	finish func()
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.finish)
+	}
+	return n, err
+}
+
+func (b *traceBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.finish)
+	return err
+}
+
+// DefaultLatencyBuckets are the upper bounds of histogram buckets
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second, 10 * time.Second,
+}
+
+// Histogram is a snapshot of the latency of one host,
+// Counts[i] is the number of requests not slower than Buckets[i],
+// the last count is for requests slower than every bucket
+type Histogram struct {
+	Buckets []time.Duration
+	Counts  []int64
+	Count   int64
+	Sum     time.Duration
+}
+
+// HostHistograms keeps a latency histogram per host
+type HostHistograms struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	hosts   map[string]*Histogram
+}
+
+// NewHostHistograms creates histograms with the bucket upper bounds,
+// DefaultLatencyBuckets is used if buckets is empty
+func NewHostHistograms(buckets ...time.Duration) *HostHistograms {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	b := append([]time.Duration(nil), buckets...)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	return &HostHistograms{buckets: b, hosts: make(map[string]*Histogram)}
+}
+
+// Observe records a request to host taking d
+func (h *HostHistograms) Observe(host string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist, ok := h.hosts[host]
+	if !ok {
+		hist = &Histogram{Buckets: h.buckets, Counts: make([]int64, len(h.buckets)+1)}
+		h.hosts[host] = hist
+	}
+	i := sort.Search(len(h.buckets), func(i int) bool { return d <= h.buckets[i] })
+	hist.Counts[i]++
+	hist.Count++
+	hist.Sum += d
+}
+
+// Snapshot returns a copy of the histograms by host
+func (h *HostHistograms) Snapshot() map[string]Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string]Histogram, len(h.hosts))
+	for host, hist := range h.hosts {
+		c := *hist
+		c.Counts = append([]int64(nil), hist.Counts...)
+		out[host] = c
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package httpclient_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+	traced := make(chan *httpclient.Timing, 2)
+	hist := httpclient.NewHostHistograms()
+	c, err := httpclient.New(&httpclient.Options{Trace: &httpclient.TraceOptions{
+		OnTrace:    func(req *http.Request, t *httpclient.Timing) { traced <- t },
+		Histograms: hist,
+	}})
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		timing, ok := httpclient.TimingFrom(resp)
+		assert.True(t, ok)
+		_, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		got := <-traced
+		assert.Equal(t, timing, got)
+		assert.Equal(t, u.Host, got.Host)
+		assert.True(t, got.FirstByte >= 10*time.Millisecond)
+		assert.True(t, got.Total >= got.FirstByte)
+		if i == 0 {
+			assert.False(t, got.Reused)
+			assert.True(t, got.Connect > 0)
+		} else {
+			assert.True(t, got.Reused)
+		}
+	}
+	h := hist.Snapshot()[u.Host]
+	assert.Equal(t, int64(2), h.Count)
+	assert.Len(t, h.Counts, len(httpclient.DefaultLatencyBuckets)+1)
+	assert.Equal(t, int64(0), h.Counts[0])
+}
+
+func TestHostHistograms(t *testing.T) {
+	hist := httpclient.NewHostHistograms(time.Second, 10*time.Millisecond)
+	hist.Observe("a", 5*time.Millisecond)
+	hist.Observe("a", time.Second)
+	hist.Observe("a", time.Minute)
+	h := hist.Snapshot()["a"]
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, time.Second}, h.Buckets)
+	assert.Equal(t, []int64{1, 1, 1}, h.Counts)
+	assert.Equal(t, time.Minute+time.Second+5*time.Millisecond, h.Sum)
+}
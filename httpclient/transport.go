@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chassis/foundation/tlsutil"
+)
+
+// TransportOptions configures the connections of the client
+type TransportOptions struct {
+	// Proxy 代理地址，如 http://proxy:8080，为空时不使用代理
+	Proxy string
+	// ProxyFromEnvironment 使用HTTP_PROXY、HTTPS_PROXY与NO_PROXY环境变量，Proxy为空时生效
+	ProxyFromEnvironment bool
+	DialTimeout          time.Duration // 默认30s
+	KeepAlive            time.Duration // TCP keep-alive间隔，默认30s
+	MaxIdleConns         int           // 所有host的最大空闲连接数，默认100
+	IdleConnTimeout      time.Duration // 空闲连接关闭时间，默认90s
+	// DisableHTTP2 只使用HTTP/1.1；默认在https连接上协商HTTP/2，自定义的TLS配置与DialContext不影响协商
+	DisableHTTP2 bool
+	// UnixSocket dials the unix socket for every request, the host of request urls is ignored
+	UnixSocket string
+}
+
+func (o *TransportOptions) setDefaults() {
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = 30 * time.Second
+	}
+	if o.KeepAlive <= 0 {
+		o.KeepAlive = 30 * time.Second
+	}
+	if o.MaxIdleConns <= 0 {
+		o.MaxIdleConns = 100
+	}
+	if o.IdleConnTimeout <= 0 {
+		o.IdleConnTimeout = 90 * time.Second
+	}
+}
+
+// newTransport builds the transport of a client, it is only called by New
+// so that the transport is never changed once requests are sent
+func newTransport(o Options) (*http.Transport, error) {
+	tlsConfig, err := clientTLSConfig(o)
+	if err != nil {
+		return nil, err
+	}
+	to := o.Transport
+	to.setDefaults()
+	dialer := &net.Dialer{Timeout: to.DialTimeout, KeepAlive: to.KeepAlive}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          to.MaxIdleConns,
+		MaxIdleConnsPerHost:   o.ConnsPerHost,
+		IdleConnTimeout:       to.IdleConnTimeout,
+		TLSHandshakeTimeout:   o.HandshakeTimeout,
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		DisableCompression:    !o.Compressed,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     !to.DisableHTTP2,
+	}
+	switch {
+	case to.Proxy != "":
+		u, err := url.Parse(to.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("httpclient: invalid proxy %s: %w", to.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	case to.ProxyFromEnvironment:
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+	if to.UnixSocket != "" {
+		socket := to.UnixSocket
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+	return transport, nil
+}
+
+// clientTLSConfig returns TLSConfig, or the config built from TLSOptions,
+// with TLSVersion as the minimum version if it is set and the config does not set one
+func clientTLSConfig(o Options) (*tls.Config, error) {
+	var cfg *tls.Config
+	switch {
+	case o.TLSConfig != nil:
+		cfg = o.TLSConfig.Clone()
+	case len(o.TLSOptions) > 0:
+		var err error
+		cfg, err = tlsutil.GetClientTLSConfig(o.TLSOptions...)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		cfg = &tls.Config{}
+	}
+	if cfg.MinVersion == 0 && o.TLSVersion != 0 {
+		cfg.MinVersion = uint16(o.TLSVersion)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,129 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/go-chassis/foundation/tlsutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportOptions(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	})
+
+	t.Run("tls version is applied", func(t *testing.T) {
+		s := httptest.NewUnstartedServer(ok)
+		s.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+		s.StartTLS()
+		defer s.Close()
+		// a default client reaches a TLS 1.2 server
+		c, err := httpclient.New(&httpclient.Options{
+			TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), s.URL, nil)
+		assert.NoError(t, err)
+		resp.Body.Close()
+
+		c, err = httpclient.New(&httpclient.Options{
+			TLSVersion: tls.VersionTLS13,
+			TLSOptions: []tlsutil.TLSOption{tlsutil.WithVerifyPeer(false)},
+		})
+		assert.NoError(t, err)
+		_, err = c.Get(context.Background(), s.URL, nil)
+		assert.Error(t, err)
+	})
+	t.Run("http2 is negotiated with a custom tls config", func(t *testing.T) {
+		proto := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		})
+		s := httptest.NewUnstartedServer(proto)
+		s.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+		s.StartTLS()
+		defer s.Close()
+		for _, disable := range []bool{false, true} {
+			c, err := httpclient.New(&httpclient.Options{
+				TLSConfig: &tls.Config{InsecureSkipVerify: true},
+				Transport: httpclient.TransportOptions{DisableHTTP2: disable},
+			})
+			assert.NoError(t, err)
+			resp, err := c.Get(context.Background(), s.URL, nil)
+			if !assert.NoError(t, err) {
+				continue
+			}
+			b, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if disable {
+				assert.Equal(t, "HTTP/1.1", string(b))
+			} else {
+				assert.Equal(t, "HTTP/2.0", string(b))
+			}
+		}
+	})
+	t.Run("concurrent https requests", func(t *testing.T) {
+		s := httptest.NewTLSServer(ok)
+		defer s.Close()
+		c, err := httpclient.New(&httpclient.Options{
+			TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		})
+		assert.NoError(t, err)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := c.Get(context.Background(), s.URL, nil)
+				if assert.NoError(t, err) {
+					resp.Body.Close()
+				}
+			}()
+		}
+		wg.Wait()
+	})
+	t.Run("proxy", func(t *testing.T) {
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("proxied " + r.URL.String()))
+		}))
+		defer proxy.Close()
+		c, err := httpclient.New(&httpclient.Options{Transport: httpclient.TransportOptions{Proxy: proxy.URL}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), "http://example.invalid/x", nil)
+		assert.NoError(t, err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "proxied http://example.invalid/x", string(b))
+
+		_, err = httpclient.New(&httpclient.Options{Transport: httpclient.TransportOptions{Proxy: "://bad"}})
+		assert.Error(t, err)
+	})
+	t.Run("unix socket", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "httpclient")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+		socket := filepath.Join(dir, "api.sock")
+		l, err := net.Listen("unix", socket)
+		assert.NoError(t, err)
+		s := httptest.NewUnstartedServer(ok)
+		s.Listener = l
+		s.Start()
+		defer s.Close()
+		c, err := httpclient.New(&httpclient.Options{Transport: httpclient.TransportOptions{UnixSocket: socket}})
+		assert.NoError(t, err)
+		resp, err := c.Get(context.Background(), "http://docker/v1/info", nil)
+		assert.NoError(t, err)
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "docker", string(b))
+	})
+}
@@ -0,0 +1,177 @@
+// Licensed to the Apache Software Foundation (ASF) under one or more
+// contributor license agreements.  See the NOTICE file distributed with
+// this work for additional information regarding copyright ownership.
+// The ASF licenses this file to You under the Apache License, Version 2.0
+// (the "License"); you may not use this file except in compliance with
+// the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+)
+
+// long poll defaults
+const (
+	DefaultRevisionParam  = "revision"
+	DefaultWaitParam      = "wait"
+	DefaultRevisionHeader = "X-Revision"
+	DefaultWatchWait      = 30 * time.Second
+)
+
+// watchGrace is added to the wait of a long poll before the client gives up on the server
+const watchGrace = 10 * time.Second
+
+// WatchEvent is a change of the watched resource
+type WatchEvent struct {
+	Revision string
+	Body     []byte
+}
+
+// WatchOptions configures Watch.
+// Each poll sends the last known revision and the wait in seconds as query parameters,
+// the server holds the request until the resource changes or the wait elapses.
+// It answers 200 with the new revision in RevisionHeader, or 304 if nothing changed
+type WatchOptions struct {
+	// Revision 起始版本，为空时首次请求立即返回当前内容
+	Revision       string
+	RevisionParam  string
+	WaitParam      string
+	Wait           time.Duration
+	RevisionHeader string
+	// Backoff 请求失败或服务端未等待就返回无变化后的等待时间，为空时使用backoff.GetBackoff()
+	Backoff backoff.Backoff
+	// BufferSize 事件channel的缓冲大小
+	BufferSize int
+	// OnError reports failed polls, the watcher polls again after backoff
+	OnError func(err error)
+}
+
+func (o WatchOptions) withDefaults() WatchOptions {
+	if o.RevisionParam == "" {
+		o.RevisionParam = DefaultRevisionParam
+	}
+	if o.WaitParam == "" {
+		o.WaitParam = DefaultWaitParam
+	}
+	if o.Wait <= 0 {
+		o.Wait = DefaultWatchWait
+	}
+	if o.RevisionHeader == "" {
+		o.RevisionHeader = DefaultRevisionHeader
+	}
+	if o.Backoff == nil {
+		o.Backoff = backoff.GetBackoff()
+	}
+	return o
+}
+
+// Watch long polls rawURL and delivers every new revision through the returned channel,
+// the channel is closed when ctx is done
+func (r *Requests) Watch(ctx context.Context, rawURL string, headers http.Header, opts WatchOptions) <-chan *WatchEvent {
+	opts = opts.withDefaults()
+	events := make(chan *WatchEvent, opts.BufferSize)
+	go func() {
+		defer close(events)
+		client := r.streaming()
+		revision := opts.Revision
+		failures, early := 0, 0
+		for {
+			start := time.Now()
+			ev, err := client.poll(ctx, rawURL, headers, revision, opts)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				if opts.OnError != nil {
+					opts.OnError(err)
+				}
+				if !sleep(ctx, opts.Backoff.Delay(failures)) {
+					return
+				}
+				failures++
+				continue
+			}
+			failures = 0
+			if ev == nil || ev.Revision == revision {
+				// a server which does not hold the poll would be polled in a busy loop
+				if time.Since(start) >= opts.Wait/2 {
+					early = 0
+					continue
+				}
+				if !sleep(ctx, opts.Backoff.Delay(early)) {
+					return
+				}
+				early++
+				continue
+			}
+			early = 0
+			revision = ev.Revision
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// poll sends one long poll request, it returns nil if the resource did not change
+func (r *Requests) poll(ctx context.Context, rawURL string, headers http.Header, revision string, opts WatchOptions) (*WatchEvent, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	if revision != "" {
+		q.Set(opts.RevisionParam, revision)
+	}
+	q.Set(opts.WaitParam, strconv.Itoa(int(opts.Wait/time.Second)))
+	u.RawQuery = q.Encode()
+
+	// the request may set headers, keep the caller's map untouched
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		h[k] = v
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Wait+watchGrace)
+	defer cancel()
+	resp, err := r.Get(ctx, u.String(), h)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
+	if err := CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	rev := resp.Header.Get(opts.RevisionHeader)
+	if rev == "" {
+		// without a revision the next poll would return at once
+		return nil, fmt.Errorf("httpclient: watch response misses header %s", opts.RevisionHeader)
+	}
+	return &WatchEvent{Revision: rev, Body: body}, nil
+}
@@ -0,0 +1,135 @@
+package httpclient_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/httpclient"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestWatch(t *testing.T) {
+	var revision, polls atomic.Int32
+	revision.Store(1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if polls.Inc() == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "1", r.URL.Query().Get("wait"))
+		cur := fmt.Sprint(revision.Load())
+		if r.URL.Query().Get("revision") == cur {
+			// hold the poll a little, then report no change
+			time.Sleep(20 * time.Millisecond)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set(httpclient.DefaultRevisionHeader, cur)
+		fmt.Fprint(w, "v"+cur)
+	}))
+	defer s.Close()
+
+	c, err := httpclient.New(nil)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	var errs atomic.Int32
+	events := c.Watch(ctx, s.URL, nil, httpclient.WatchOptions{
+		Wait:    time.Second,
+		Backoff: fastBackoff,
+		OnError: func(err error) { errs.Inc() },
+	})
+
+	ev := <-events
+	assert.Equal(t, &httpclient.WatchEvent{Revision: "1", Body: []byte("v1")}, ev)
+	assert.Equal(t, int32(1), errs.Load())
+
+	revision.Store(2)
+	ev = <-events
+	assert.Equal(t, &httpclient.WatchEvent{Revision: "2", Body: []byte("v2")}, ev)
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
+
+func TestWatchMissingRevision(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "v")
+	}))
+	defer s.Close()
+
+	c, err := httpclient.New(nil)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	c.Watch(ctx, s.URL+"?app=a", nil, httpclient.WatchOptions{
+		Backoff: fastBackoff,
+		OnError: func(err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		},
+	})
+	assert.Contains(t, (<-errs).Error(), httpclient.DefaultRevisionHeader)
+}
+
+func TestWatchHedged(t *testing.T) {
+	var firstPolls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("revision") == "" {
+			firstPolls.Inc()
+		}
+		// a long poll held past the hedge delay
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set(httpclient.DefaultRevisionHeader, "1")
+		fmt.Fprint(w, "v1")
+	}))
+	defer s.Close()
+
+	c, err := httpclient.New(&httpclient.Options{
+		Hedge: &httpclient.HedgeOptions{Delay: 20 * time.Millisecond, MaxRatio: 1},
+		Cache: &httpclient.CacheOptions{},
+	})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ev := <-c.Watch(ctx, s.URL, nil, httpclient.WatchOptions{Backoff: fastBackoff})
+	assert.Equal(t, "1", ev.Revision)
+	assert.Equal(t, int32(1), firstPolls.Load())
+}
+
+func TestWatchUnchanged(t *testing.T) {
+	var polls atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls.Inc()
+		// answers at once with the same revision instead of holding the poll
+		w.Header().Set(httpclient.DefaultRevisionHeader, "1")
+		fmt.Fprint(w, "v1")
+	}))
+	defer s.Close()
+
+	c, err := httpclient.New(nil)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// watchers share the headers of the caller
+	headers := http.Header{"X-App": {"a"}}
+	for i := 0; i < 2; i++ {
+		events := c.Watch(ctx, s.URL, headers, httpclient.WatchOptions{Wait: time.Second, Backoff: fastBackoff})
+		assert.Equal(t, "1", (<-events).Revision)
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.True(t, polls.Load() < 60, polls.Load())
+	assert.Equal(t, http.Header{"X-App": {"a"}}, headers)
+}
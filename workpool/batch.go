@@ -0,0 +1,231 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/atomic"
+)
+
+// BatchProgress is a snapshot of the progress of a batch
+type BatchProgress struct {
+	Total     int // 任务总数
+	Finished  int // 已结束的任务数，包括失败和取消的任务
+	Succeeded int // 执行成功的任务数
+	Failed    int // 执行失败的任务数
+	Cancelled int // 被取消的任务数
+}
+
+// BatchHandle tracks a batch of tasks submitted by SubmitBatch
+type BatchHandle struct {
+	outcomes  []*TaskOutcome
+	ctx       context.Context
+	cancel    context.CancelFunc
+	failFast  bool
+	done      chan struct{}
+	finished  atomic.Int32
+	succeeded atomic.Int32
+	failed    atomic.Int32
+	cancelled atomic.Int32
+}
+
+// SubmitBatch submits tasks to pool in the background and returns at once.
+// Tasks get a context which is done when the batch is cancelled or the pool is force stopped.
+func SubmitBatch(pool Pool, tasks []*Task) *BatchHandle {
+	return submitBatch(context.Background(), pool, tasks, 0, false)
+}
+
+// submitBatch submits tasks with at most parallelism of them in the pool at the same time,
+// 0 means no limit. If failFast is set, the first failure cancels the rest.
+func submitBatch(ctx context.Context, pool Pool, tasks []*Task, parallelism int, failFast bool) *BatchHandle {
+	b := &BatchHandle{
+		outcomes: make([]*TaskOutcome, len(tasks)),
+		failFast: failFast,
+		done:     make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(ctx)
+	for i, task := range tasks {
+		b.outcomes[i] = &TaskOutcome{ID: task.ID}
+	}
+	if len(tasks) == 0 {
+		b.cancel()
+		close(b.done)
+		return b
+	}
+	go b.submit(pool, tasks, parallelism)
+	go b.giveUp()
+	return b
+}
+
+// giveUp cancels the tasks not started once the batch is cancelled,
+// instead of waiting for the ones still queued in a busy pool
+func (b *BatchHandle) giveUp() {
+	<-b.ctx.Done()
+	for _, o := range b.outcomes {
+		if o.giveUp(b.ctx.Err()) {
+			b.finish(o)
+		}
+	}
+}
+
+func (b *BatchHandle) submit(pool Pool, tasks []*Task, parallelism int) {
+	var sem chan struct{}
+	if parallelism > 0 {
+		sem = make(chan struct{}, parallelism)
+	}
+	for i, task := range tasks {
+		o := b.outcomes[i]
+		task := task
+		acquired := false
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+				acquired = true
+			case <-b.ctx.Done():
+			}
+		}
+		err := b.ctx.Err()
+		if err == nil {
+			err = pool.SubmitContext(b.ctx, &Task{
+				ID: task.ID,
+				Run: func(poolCtx context.Context) error {
+					return runWithOutcome(b.ctx, poolCtx, task, o, func() {
+						if sem != nil {
+							<-sem
+						}
+						b.finish(o)
+					})
+				},
+				// dropped by RejectDiscardOldest or returned by StopContext
+				OnDiscard: func() {
+					if o.giveUp(ErrTaskDiscarded) {
+						task.discarded()
+						if sem != nil {
+							<-sem
+						}
+						b.finish(o)
+					}
+				},
+			})
+		}
+		if err != nil {
+			if acquired {
+				<-sem
+			}
+			if o.giveUp(err) {
+				b.finish(o)
+			}
+		}
+	}
+}
+
+func (b *BatchHandle) finish(o *TaskOutcome) {
+	switch o.State {
+	case TaskSucceeded:
+		b.succeeded.Inc()
+	case TaskFailed:
+		b.failed.Inc()
+		if b.failFast {
+			b.cancel()
+		}
+	default:
+		b.cancelled.Inc()
+	}
+	if int(b.finished.Inc()) == len(b.outcomes) {
+		b.cancel()
+		close(b.done)
+	}
+}
+
+// Wait waits for all tasks finished, it returns the error of the first task not succeeded
+func (b *BatchHandle) Wait() error {
+	<-b.done
+	return firstError(b.outcomes)
+}
+
+// WaitContext is Wait which gives up waiting when ctx is done, the batch keeps running
+func (b *BatchHandle) WaitContext(ctx context.Context) error {
+	select {
+	case <-b.done:
+		return firstError(b.outcomes)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done returns a channel closed when all tasks finished
+func (b *BatchHandle) Done() <-chan struct{} {
+	return b.done
+}
+
+// Cancel cancels the tasks not started yet and the context of the running ones
+func (b *BatchHandle) Cancel() {
+	b.cancel()
+}
+
+// Results returns the outcome of every task in submission order, it must be called after Wait
+func (b *BatchHandle) Results() []*TaskOutcome {
+	return b.outcomes
+}
+
+// Progress returns the progress of the batch, it can be called at any time
+func (b *BatchHandle) Progress() BatchProgress {
+	return BatchProgress{
+		Total:     len(b.outcomes),
+		Finished:  int(b.finished.Load()),
+		Succeeded: int(b.succeeded.Load()),
+		Failed:    int(b.failed.Load()),
+		Cancelled: int(b.cancelled.Load()),
+	}
+}
+
+// ForEach calls fn(ctx, i) for i in [0, n) on the pool registered with poolName,
+// with at most parallelism calls running at the same time, 0 means no limit.
+// The first error cancels the calls not started yet and is returned.
+func ForEach(ctx context.Context, poolName string, n, parallelism int, fn func(ctx context.Context, i int) error) error {
+	pool, ok := GetPool(poolName)
+	if !ok {
+		return ErrPoolNotFound
+	}
+	tasks := make([]*Task, n)
+	for i := range tasks {
+		i := i
+		tasks[i] = &Task{
+			ID: strconv.Itoa(i),
+			Run: func(ctx context.Context) error {
+				return fn(ctx, i)
+			},
+		}
+	}
+	return submitBatch(ctx, pool, tasks, parallelism, true).Wait()
+}
+
+// Map is ForEach which collects the value returned by fn(ctx, i) into the i-th element of the result
+func Map(ctx context.Context, poolName string, n, parallelism int, fn func(ctx context.Context, i int) (interface{}, error)) ([]interface{}, error) {
+	results := make([]interface{}, n)
+	err := ForEach(ctx, poolName, n, parallelism, func(ctx context.Context, i int) error {
+		v, err := fn(ctx, i)
+		results[i] = v
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestSubmitBatch(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(4))
+	defer pool.Stop()
+
+	t.Run("wait all with per task results", func(t *testing.T) {
+		boom := errors.New("boom")
+		tasks := make([]*Task, 100)
+		for i := range tasks {
+			i := i
+			tasks[i] = &Task{ID: strconv.Itoa(i), Run: func(ctx context.Context) error {
+				if i == 42 {
+					return boom
+				}
+				return nil
+			}}
+		}
+		b := SubmitBatch(pool, tasks)
+		err := b.Wait()
+		assert.True(errors.Is(err, boom))
+		results := b.Results()
+		assert.Equal(100, len(results))
+		assert.Equal("42", results[42].ID)
+		assert.Equal(TaskFailed, results[42].State)
+		assert.Equal(TaskSucceeded, results[0].State)
+		assert.Equal(BatchProgress{Total: 100, Finished: 100, Succeeded: 99, Failed: 1}, b.Progress())
+	})
+
+	t.Run("cancel remaining", func(t *testing.T) {
+		started := make(chan struct{}, 100)
+		tasks := make([]*Task, 100)
+		for i := range tasks {
+			tasks[i] = &Task{ID: strconv.Itoa(i), Run: func(ctx context.Context) error {
+				started <- struct{}{}
+				<-ctx.Done()
+				return ctx.Err()
+			}}
+		}
+		b := SubmitBatch(pool, tasks)
+		<-started
+		b.Cancel()
+		assert.Error(b.Wait())
+		p := b.Progress()
+		assert.Equal(100, p.Finished)
+		assert.True(p.Cancelled > 90, p.Cancelled)
+		assert.Equal(0, p.Succeeded)
+	})
+
+	t.Run("cancel gives up tasks queued in a busy pool", func(t *testing.T) {
+		busy := New("busy", WithMaxWorkers(1))
+		release := make(chan struct{})
+		busy.Submit(&Task{ID: "busy", F: func() { <-release }})
+		defer busy.Stop()
+		defer close(release)
+		tasks := make([]*Task, 4)
+		for i := range tasks {
+			tasks[i] = &Task{ID: strconv.Itoa(i), F: func() {}}
+		}
+		b := SubmitBatch(busy, tasks)
+		time.Sleep(20 * time.Millisecond)
+		b.Cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.True(errors.Is(b.WaitContext(ctx), context.Canceled))
+	})
+
+	t.Run("tasks discarded by the pool finish the batch", func(t *testing.T) {
+		discarding := New("discard", WithMaxWorkers(1), WithQueueSize(1), WithWorkStealing(), WithRejectPolicy(RejectDiscardOldest))
+		defer discarding.Stop()
+		started, release := make(chan struct{}), make(chan struct{})
+		discarding.Submit(&Task{ID: "busy", F: func() {
+			close(started)
+			<-release
+		}})
+		<-started
+		tasks := make([]*Task, 3)
+		for i := range tasks {
+			tasks[i] = &Task{ID: strconv.Itoa(i), F: func() {}}
+		}
+		b := SubmitBatch(discarding, tasks)
+		time.AfterFunc(50*time.Millisecond, func() { close(release) })
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.True(errors.Is(b.WaitContext(ctx), ErrTaskDiscarded))
+		assert.Equal(BatchProgress{Total: 3, Finished: 3, Succeeded: 1, Cancelled: 2}, b.Progress())
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		b := SubmitBatch(pool, nil)
+		assert.NoError(b.Wait())
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(b.WaitContext(ctx))
+	})
+}
+
+func TestMapAndForEach(t *testing.T) {
+	assert := assert.New(t)
+	Register("batch", New("batch", WithMaxWorkers(8)))
+	defer func() {
+		pool, _ := GetPool("batch")
+		pool.Stop()
+		Unregister("batch")
+	}()
+
+	t.Run("map keeps order with bounded parallelism", func(t *testing.T) {
+		in := []int{5, 3, 8, 1, 9, 2, 7}
+		var running, maxRunning atomic.Int32
+		out, err := Map(context.Background(), "batch", len(in), 2, func(ctx context.Context, i int) (interface{}, error) {
+			n := running.Inc()
+			for m := maxRunning.Load(); n > m && !maxRunning.CAS(m, n); m = maxRunning.Load() {
+			}
+			time.Sleep(time.Duration(in[i]) * time.Millisecond)
+			running.Dec()
+			return in[i] * 2, nil
+		})
+		assert.NoError(err)
+		assert.Equal([]interface{}{10, 6, 16, 2, 18, 4, 14}, out)
+		assert.True(maxRunning.Load() <= 2)
+	})
+
+	t.Run("for each stops at first error", func(t *testing.T) {
+		boom := errors.New("boom")
+		var c atomic.Int32
+		err := ForEach(context.Background(), "batch", 1000, 1, func(ctx context.Context, i int) error {
+			c.Inc()
+			if i == 3 {
+				return boom
+			}
+			return nil
+		})
+		assert.True(errors.Is(err, boom))
+		assert.True(c.Load() < 10, c.Load())
+	})
+
+	t.Run("stopped pool", func(t *testing.T) {
+		stopped := New("stopped")
+		stopped.Stop()
+		Register("stopped", stopped)
+		defer Unregister("stopped")
+		done := make(chan error, 1)
+		go func() {
+			done <- ForEach(context.Background(), "stopped", 2, 1, func(ctx context.Context, i int) error {
+				return nil
+			})
+		}()
+		select {
+		case err := <-done:
+			assert.True(errors.Is(err, ErrPoolStopped))
+		case <-time.After(time.Second):
+			t.Fatal("ForEach on a stopped pool did not return")
+		}
+	})
+
+	t.Run("unknown pool", func(t *testing.T) {
+		_, err := Map(context.Background(), "unknown", 1, 1, func(ctx context.Context, i int) (interface{}, error) {
+			return nil, nil
+		})
+		assert.Equal(ErrPoolNotFound, err)
+	})
+}
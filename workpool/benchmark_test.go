@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/gopool"
+)
+
+// benchmarks of tiny tasks submitted from parallel goroutines,
+// compare the dispatcher pool, the work stealing pool and gopool
+
+func benchmarkPool(b *testing.B, pool Pool) {
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	task := &Task{ID: "bench", F: wg.Done}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Submit(task)
+		}
+	})
+	wg.Wait()
+	b.StopTimer()
+	pool.Stop()
+}
+
+func BenchmarkDispatcherPool(b *testing.B) {
+	benchmarkPool(b, New("bench", WithMaxWorkers(runtime.NumCPU()), WithQueueSize(1024)))
+}
+
+func BenchmarkStealingPool(b *testing.B) {
+	benchmarkPool(b, New("bench", WithWorkStealing(), WithMaxWorkers(runtime.NumCPU()), WithQueueSize(1024)))
+}
+
+func BenchmarkGoPool(b *testing.B) {
+	pool := gopool.New(gopool.Configure().Workers(runtime.NumCPU()).Idle(time.Minute))
+	var wg sync.WaitGroup
+	wg.Add(b.N)
+	f := func(context.Context) { wg.Done() }
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			pool.Do(f)
+		}
+	})
+	wg.Wait()
+	b.StopTimer()
+	pool.Done()
+}
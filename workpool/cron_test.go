@@ -0,0 +1,62 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	assert := assert.New(t)
+	base := time.Date(2021, 3, 1, 10, 30, 15, 0, time.UTC) // Monday
+
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, 3, 1, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 3, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 12 * * *", time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2021, 3, 2, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 1-5", time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"5,35 10 * * *", time.Date(2021, 3, 1, 10, 35, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.spec)
+		assert.NoError(err, c.spec)
+		assert.Equal(c.next, s.Next(base), c.spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(spec)
+		assert.Error(err, spec)
+	}
+
+	s, err := ParseCron("0 0 30 2 *")
+	assert.NoError(err)
+	assert.True(s.Next(base).IsZero())
+}
@@ -0,0 +1,236 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrDependencyFailed is the error of a task skipped because one of its dependencies did not succeed
+	ErrDependencyFailed = errors.New("workpool: dependency failed")
+)
+
+// DAGReport holds the outcome of every task, in the order they were added
+type DAGReport struct {
+	Outcomes []*TaskOutcome
+}
+
+// Get returns the outcome of the task, or nil if there is no such task
+func (r *DAGReport) Get(id string) *TaskOutcome {
+	for _, o := range r.Outcomes {
+		if o.ID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+type dagNode struct {
+	task       *Task
+	deps       []string
+	dependents []*dagNode
+	outcome    *TaskOutcome
+	waiting    int  // 未完成的依赖数
+	submitted  bool // 已提交到协程池且尚未结束
+}
+
+// DAG runs tasks in dependency order, tasks whose dependencies are done run concurrently on a pool.
+// A task runs only if all of its dependencies succeeded, otherwise it is skipped.
+type DAG struct {
+	nodes []*dagNode
+	index map[string]*dagNode
+}
+
+// NewDAG creates an empty DAG
+func NewDAG() *DAG {
+	return &DAG{index: make(map[string]*dagNode)}
+}
+
+// Add adds a task which runs after the tasks with ID in deps,
+// dependencies may be added later but must exist before Run
+func (d *DAG) Add(task *Task, deps ...string) error {
+	if task == nil || task.ID == "" {
+		return errors.New("workpool: task and task ID are required")
+	}
+	if _, ok := d.index[task.ID]; ok {
+		return fmt.Errorf("workpool: duplicate task %s", task.ID)
+	}
+	n := &dagNode{task: task, deps: deps}
+	d.nodes = append(d.nodes, n)
+	d.index[task.ID] = n
+	return nil
+}
+
+// Validate checks that every dependency exists and there is no cycle
+func (d *DAG) Validate() error {
+	for _, n := range d.nodes {
+		for _, dep := range n.deps {
+			if _, ok := d.index[dep]; !ok {
+				return fmt.Errorf("workpool: task %s depends on unknown task %s", n.task.ID, dep)
+			}
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	color := make(map[string]int, len(d.nodes))
+	var path []string
+	var visit func(n *dagNode) error
+	visit = func(n *dagNode) error {
+		id := n.task.ID
+		switch color[id] {
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					return fmt.Errorf("workpool: dependency cycle %s", strings.Join(append(path[i:], id), " -> "))
+				}
+			}
+		case visited:
+			return nil
+		}
+		color[id] = visiting
+		path = append(path, id)
+		for _, dep := range n.deps {
+			if err := visit(d.index[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		color[id] = visited
+		return nil
+	}
+	for _, n := range d.nodes {
+		if err := visit(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run validates the DAG and runs all tasks on pool, it returns when every task is done or skipped.
+// Tasks get a context cancelled when either ctx is done or the pool is force stopped.
+// Tasks discarded by the pool are cancelled, once ctx is done Run only waits for the tasks already started.
+// Run must not be called concurrently on the same DAG.
+// The returned error is a validation error or the error of the first task not succeeded.
+func (d *DAG) Run(ctx context.Context, pool Pool) (*DAGReport, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	report := &DAGReport{Outcomes: make([]*TaskOutcome, 0, len(d.nodes))}
+	for _, n := range d.nodes {
+		n.outcome = &TaskOutcome{ID: n.task.ID}
+		n.dependents = nil
+		n.waiting = len(n.deps)
+		n.submitted = false
+		report.Outcomes = append(report.Outcomes, n.outcome)
+	}
+	for _, n := range d.nodes {
+		for _, dep := range n.deps {
+			d.index[dep].dependents = append(d.index[dep].dependents, n)
+		}
+	}
+
+	done := make(chan *dagNode, len(d.nodes))
+	running := 0
+	var ready []*dagNode
+	for _, n := range d.nodes {
+		if n.waiting == 0 {
+			ready = append(ready, n)
+		}
+	}
+	ctxDone := ctx.Done()
+	for len(ready) > 0 || running > 0 {
+		for i := 0; i < len(ready); i++ {
+			n := ready[i]
+			if err := d.submit(ctx, pool, n, done); err != nil {
+				n.outcome.giveUp(err)
+				ready = append(ready, d.resolve(n)...)
+				continue
+			}
+			n.submitted = true
+			running++
+		}
+		ready = ready[:0]
+		if running == 0 {
+			break
+		}
+		select {
+		case n := <-done:
+			n.submitted = false
+			running--
+			ready = append(ready, d.resolve(n)...)
+		case <-ctxDone:
+			// tasks still queued may wait in a busy pool for long, do not wait for them
+			ctxDone = nil
+			for _, n := range d.nodes {
+				if n.submitted && n.outcome.giveUp(ctx.Err()) {
+					n.submitted = false
+					running--
+					ready = append(ready, d.resolve(n)...)
+				}
+			}
+		}
+	}
+
+	return report, firstError(report.Outcomes)
+}
+
+func (d *DAG) submit(ctx context.Context, pool Pool, n *dagNode, done chan<- *dagNode) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return pool.SubmitContext(ctx, &Task{
+		ID: n.task.ID,
+		Run: func(poolCtx context.Context) error {
+			return runWithOutcome(ctx, poolCtx, n.task, n.outcome, func() { done <- n })
+		},
+		OnDiscard: func() {
+			if n.outcome.giveUp(ErrTaskDiscarded) {
+				n.task.discarded()
+				done <- n
+			}
+		},
+	})
+}
+
+// resolve updates the dependents of a finished task, it returns the dependents ready to run
+// and skips the ones which can never run
+func (d *DAG) resolve(n *dagNode) []*dagNode {
+	var ready []*dagNode
+	for _, dep := range n.dependents {
+		if dep.outcome.State != TaskPending {
+			continue
+		}
+		if n.outcome.State != TaskSucceeded {
+			dep.outcome.State = TaskSkipped
+			dep.outcome.Err = fmt.Errorf("%w: %s", ErrDependencyFailed, n.task.ID)
+			ready = append(ready, d.resolve(dep)...)
+			continue
+		}
+		dep.waiting--
+		if dep.waiting == 0 {
+			ready = append(ready, dep)
+		}
+	}
+	return ready
+}
@@ -0,0 +1,186 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDAG_Run(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(4))
+	defer pool.Stop()
+
+	t.Run("tasks run in dependency order", func(t *testing.T) {
+		var mux sync.Mutex
+		var order []string
+		record := func(id string) *Task {
+			return &Task{ID: id, F: func() {
+				mux.Lock()
+				order = append(order, id)
+				mux.Unlock()
+			}}
+		}
+		d := NewDAG()
+		assert.NoError(d.Add(record("heartbeat"), "register"))
+		assert.NoError(d.Add(record("register"), "client"))
+		assert.NoError(d.Add(record("client"), "cert", "config"))
+		assert.NoError(d.Add(record("cert")))
+		assert.NoError(d.Add(record("config")))
+		assert.Error(d.Add(record("cert")))
+
+		report, err := d.Run(context.Background(), pool)
+		assert.NoError(err)
+		assert.Equal(5, len(report.Outcomes))
+		for _, o := range report.Outcomes {
+			assert.Equal(TaskSucceeded, o.State, o.ID)
+		}
+		assert.Equal([]string{"client", "register", "heartbeat"}, order[2:])
+	})
+
+	t.Run("failure should skip dependents only", func(t *testing.T) {
+		d := NewDAG()
+		boom := errors.New("boom")
+		assert.NoError(d.Add(&Task{ID: "a", Run: func(ctx context.Context) error { return boom }}))
+		assert.NoError(d.Add(&Task{ID: "b", F: func() {}}, "a"))
+		assert.NoError(d.Add(&Task{ID: "c", F: func() {}}, "b"))
+		assert.NoError(d.Add(&Task{ID: "d", F: func() {}}))
+
+		report, err := d.Run(context.Background(), pool)
+		assert.True(errors.Is(err, boom))
+		assert.Equal(TaskFailed, report.Get("a").State)
+		assert.Equal(TaskSkipped, report.Get("b").State)
+		assert.True(errors.Is(report.Get("b").Err, ErrDependencyFailed))
+		assert.Equal(TaskSkipped, report.Get("c").State)
+		assert.Equal(TaskSucceeded, report.Get("d").State)
+	})
+
+	t.Run("panic should fail the task", func(t *testing.T) {
+		d := NewDAG()
+		assert.NoError(d.Add(&Task{ID: "a", F: func() { panic("boom") }}))
+		assert.NoError(d.Add(&Task{ID: "b", F: func() {}}, "a"))
+		report, err := d.Run(context.Background(), pool)
+		assert.Error(err)
+		assert.Equal(TaskFailed, report.Get("a").State)
+		assert.Equal(TaskSkipped, report.Get("b").State)
+	})
+
+	t.Run("cancelled context should cancel the rest", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		d := NewDAG()
+		assert.NoError(d.Add(&Task{ID: "a", Run: func(ctx context.Context) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		}}))
+		assert.NoError(d.Add(&Task{ID: "b", F: func() {}}, "a"))
+		report, err := d.Run(ctx, pool)
+		assert.Error(err)
+		assert.Equal(TaskFailed, report.Get("a").State)
+		assert.Equal(TaskSkipped, report.Get("b").State)
+	})
+
+	t.Run("independent tasks run concurrently", func(t *testing.T) {
+		d := NewDAG()
+		for _, id := range []string{"a", "b", "c", "d"} {
+			assert.NoError(d.Add(&Task{ID: id, F: func() { time.Sleep(50 * time.Millisecond) }}))
+		}
+		start := time.Now()
+		_, err := d.Run(context.Background(), pool)
+		assert.NoError(err)
+		assert.True(time.Since(start) < 150*time.Millisecond)
+	})
+}
+
+func TestDAG_RunDiscarded(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithRejectPolicy(RejectDiscardOldest))
+	defer pool.Stop()
+	started, release := make(chan struct{}), make(chan struct{})
+	pool.Submit(&Task{ID: "busy", F: func() {
+		close(started)
+		<-release
+	}})
+	<-started
+	defer close(release)
+
+	d := NewDAG()
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(d.Add(&Task{ID: id, F: func() { t.Error("given up task should not run") }}))
+	}
+	// one of the tasks is discarded by the pool, the others are still queued at the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	report, err := d.Run(ctx, pool)
+	assert.Error(err)
+	expired := 0
+	for _, o := range report.Outcomes {
+		assert.Equal(TaskCancelled, o.State, o.ID)
+		if errors.Is(o.Err, context.DeadlineExceeded) {
+			expired++
+		}
+	}
+	assert.True(expired >= 1)
+}
+
+func TestDAG_RunDiscardedWithoutDeadline(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithWorkStealing(), WithRejectPolicy(RejectDiscardOldest))
+	defer pool.Stop()
+	started, release := make(chan struct{}), make(chan struct{})
+	pool.Submit(&Task{ID: "busy", F: func() {
+		close(started)
+		<-release
+	}})
+	<-started
+
+	d := NewDAG()
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(d.Add(&Task{ID: id, F: func() {}}))
+	}
+	assert.NoError(d.Add(&Task{ID: "after-a", F: func() {}}, "a"))
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+	report, err := d.Run(context.Background(), pool)
+	assert.True(errors.Is(err, ErrTaskDiscarded))
+	// a and b are pushed out of the queue by the tasks submitted after them
+	assert.Equal(TaskCancelled, report.Get("a").State)
+	assert.Equal(TaskCancelled, report.Get("b").State)
+	assert.Equal(TaskSkipped, report.Get("after-a").State)
+	assert.Equal(TaskSucceeded, report.Get("c").State)
+}
+
+func TestDAG_Validate(t *testing.T) {
+	assert := assert.New(t)
+	d := NewDAG()
+	assert.NoError(d.Add(&Task{ID: "a", F: func() {}}, "c"))
+	assert.NoError(d.Add(&Task{ID: "b", F: func() {}}, "a"))
+	assert.NoError(d.Add(&Task{ID: "c", F: func() {}}, "b"))
+	err := d.Validate()
+	assert.EqualError(err, "workpool: dependency cycle a -> c -> b -> a")
+
+	d = NewDAG()
+	assert.NoError(d.Add(&Task{ID: "a", F: func() {}}, "x"))
+	assert.Error(d.Validate())
+	_, err = d.Run(context.Background(), nil)
+	assert.Error(err)
+}
@@ -0,0 +1,221 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-chassis/foundation/executor"
+	"go.uber.org/atomic"
+)
+
+var (
+	_ executor.Executor = (*workerPool)(nil)
+	_ executor.Executor = (*stealingPool)(nil)
+	_ Pool              = (*executorPool)(nil)
+)
+
+// doneContext is a cancelled context, submitting with it does not wait for room
+var doneContext = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+func funcTask(f func(ctx context.Context)) *Task {
+	return &Task{Run: func(ctx context.Context) error {
+		f(ctx)
+		return nil
+	}}
+}
+
+// Execute submits f as a task, see executor.Executor
+func (p *workerPool) Execute(ctx context.Context, f func(ctx context.Context)) error {
+	return p.SubmitContext(ctx, funcTask(f))
+}
+
+// Shutdown stops the pool, see executor.Executor
+func (p *workerPool) Shutdown(ctx context.Context) error {
+	_, err := p.StopContext(ctx)
+	return err
+}
+
+func (p *workerPool) Stats() executor.Stats {
+	return executor.Stats{
+		WorkersAlive:   int64(p.workersAlive.Load()),
+		WorkersCreated: int64(p.workersCreated.Load()),
+		WorkersKilled:  int64(p.workersKilled.Load()),
+		TasksSubmitted: int64(p.tasksSubmitted.Load()),
+		TasksCompleted: int64(p.tasksConsumed.Load()),
+		TasksPanicked:  int64(p.tasksPanicked.Load()),
+		TasksDiscarded: int64(p.tasksDiscarded.Load()),
+	}
+}
+
+// Execute submits f as a task, see executor.Executor
+func (p *stealingPool) Execute(ctx context.Context, f func(ctx context.Context)) error {
+	return p.SubmitContext(ctx, funcTask(f))
+}
+
+// Shutdown stops the pool, see executor.Executor
+func (p *stealingPool) Shutdown(ctx context.Context) error {
+	_, err := p.StopContext(ctx)
+	return err
+}
+
+func (p *stealingPool) Stats() executor.Stats {
+	return executor.Stats{
+		WorkersAlive:   int64(p.workersAlive.Load()),
+		WorkersCreated: int64(p.workersCreated.Load()),
+		WorkersKilled:  int64(p.workersKilled.Load()),
+		TasksSubmitted: int64(p.tasksSubmitted.Load()),
+		TasksCompleted: int64(p.tasksConsumed.Load()),
+		TasksPanicked:  int64(p.tasksPanicked.Load()),
+		TasksDiscarded: int64(p.tasksDiscarded.Load()),
+	}
+}
+
+// AsExecutor returns the pool as an executor.Executor.
+// Pools created by this package implement it already, other implementations are wrapped,
+// their panics are recovered and logged and only task counters are reported in Stats.
+func AsExecutor(pool Pool) executor.Executor {
+	if e, ok := pool.(executor.Executor); ok {
+		return e
+	}
+	return &poolExecutor{pool: pool}
+}
+
+type poolExecutor struct {
+	pool      Pool
+	submitted atomic.Int64
+	completed atomic.Int64
+	panicked  atomic.Int64
+}
+
+func (e *poolExecutor) Execute(ctx context.Context, f func(ctx context.Context)) error {
+	err := e.pool.SubmitContext(ctx, &Task{Run: func(ctx context.Context) error {
+		defer e.completed.Inc()
+		defer func() {
+			if r := recover(); r != nil {
+				e.panicked.Inc()
+				log.Println("workpool recover:", r)
+			}
+		}()
+		f(ctx)
+		return nil
+	}})
+	if err == nil {
+		e.submitted.Inc()
+	}
+	return err
+}
+
+func (e *poolExecutor) Shutdown(ctx context.Context) error {
+	_, err := e.pool.StopContext(ctx)
+	return err
+}
+
+func (e *poolExecutor) Stats() executor.Stats {
+	return executor.Stats{
+		TasksSubmitted: e.submitted.Load(),
+		TasksCompleted: e.completed.Load(),
+		TasksPanicked:  e.panicked.Load(),
+	}
+}
+
+// FromExecutor returns a Pool running tasks on e, e.g. a gopool.Pool.
+// TrySubmit returns ErrQueueFull if e can not accept the task right now,
+// StopContext never returns unstarted tasks since e drops them.
+func FromExecutor(e executor.Executor) Pool {
+	return &executorPool{executor: e}
+}
+
+type executorPool struct {
+	executor executor.Executor
+	stopped  atomic.Bool
+}
+
+func (p *executorPool) Submit(task *Task) {
+	_ = p.SubmitContext(context.Background(), task)
+}
+
+func (p *executorPool) SubmitContext(ctx context.Context, task *Task) error {
+	if task == nil {
+		return nil
+	}
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	return p.executor.Execute(ctx, func(ctx context.Context) {
+		deliverDeadLetter(task.run(ctx), nil)
+	})
+}
+
+func (p *executorPool) TrySubmit(task *Task) error {
+	err := p.SubmitContext(doneContext, task)
+	if err == context.Canceled {
+		return ErrQueueFull
+	}
+	return err
+}
+
+func (p *executorPool) SubmitAndWait(task *Task) {
+	_ = p.SubmitAndWaitContext(context.Background(), task)
+}
+
+func (p *executorPool) SubmitAndWaitContext(ctx context.Context, task *Task) error {
+	if task == nil {
+		return nil
+	}
+	wrapped, errCh := waitable(task)
+	if err := p.SubmitContext(ctx, wrapped); err != nil {
+		return err
+	}
+	return waitTask(ctx, errCh)
+}
+
+func (p *executorPool) Stopped() bool {
+	return p.stopped.Load()
+}
+
+func (p *executorPool) Stop() {
+	_, _ = p.StopContext(context.Background())
+}
+
+func (p *executorPool) StopContext(ctx context.Context) ([]*Task, error) {
+	if p.stopped.Swap(true) {
+		return nil, nil
+	}
+	return nil, p.executor.Shutdown(ctx)
+}
+
+func (p *executorPool) Execute(ctx context.Context, f func(ctx context.Context)) error {
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	return p.executor.Execute(ctx, f)
+}
+
+func (p *executorPool) Shutdown(ctx context.Context) error {
+	_, err := p.StopContext(ctx)
+	return err
+}
+
+func (p *executorPool) Stats() executor.Stats {
+	return p.executor.Stats()
+}
@@ -0,0 +1,98 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/executor"
+	"github.com/go-chassis/foundation/gopool"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+// customPool is a Pool implemented outside of this package
+type customPool struct {
+	Pool
+}
+
+func testExecutor(t *testing.T, e executor.Executor, workerStats bool) {
+	assert := assert.New(t)
+	var c atomic.Int32
+	for i := 0; i < 10; i++ {
+		assert.NoError(e.Execute(context.Background(), func(ctx context.Context) { c.Inc() }))
+	}
+	assert.NoError(e.Execute(context.Background(), func(ctx context.Context) { panic("boom") }))
+	done := make(chan struct{})
+	assert.NoError(e.Execute(context.Background(), func(ctx context.Context) { close(done) }))
+	<-done
+	assert.NoError(e.Shutdown(context.Background()))
+	assert.Equal(int32(10), c.Load())
+	assert.Equal(executor.ErrStopped, e.Execute(context.Background(), func(ctx context.Context) {}))
+
+	stats := e.Stats()
+	assert.Equal(int64(12), stats.TasksSubmitted)
+	assert.Equal(int64(12), stats.TasksCompleted)
+	assert.Equal(int64(1), stats.TasksPanicked)
+	if workerStats {
+		assert.Equal(int64(0), stats.WorkersAlive)
+		assert.True(stats.WorkersCreated > 0)
+		assert.Equal(stats.WorkersCreated, stats.WorkersKilled)
+	}
+}
+
+func TestExecutor(t *testing.T) {
+	var recovered atomic.Int32
+	recoverFunc := WithRecoverFunc(func(r interface{}) { recovered.Inc() })
+	t.Run("dispatcher pool", func(t *testing.T) {
+		testExecutor(t, AsExecutor(New("test", WithMaxWorkers(2), recoverFunc)), true)
+	})
+	t.Run("stealing pool", func(t *testing.T) {
+		testExecutor(t, AsExecutor(New("test", WithWorkStealing(), WithMaxWorkers(2), recoverFunc)), true)
+	})
+	assert.Equal(t, int32(2), recovered.Load())
+	t.Run("custom pool", func(t *testing.T) {
+		testExecutor(t, AsExecutor(&customPool{New("test", WithMaxWorkers(2))}), false)
+	})
+	t.Run("gopool", func(t *testing.T) {
+		testExecutor(t, gopool.New(gopool.Configure().Workers(2)), true)
+	})
+}
+
+func TestFromExecutor(t *testing.T) {
+	assert := assert.New(t)
+	pool := FromExecutor(gopool.New(gopool.Configure().Workers(1)))
+	var c atomic.Int32
+	pool.Submit(&Task{ID: "inc", F: func() { c.Inc() }})
+	pool.SubmitAndWait(&Task{ID: "wait", F: func() { c.Inc() }})
+
+	block := make(chan struct{})
+	assert.NoError(pool.SubmitContext(context.Background(), &Task{ID: "block", F: func() { <-block }}))
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(ErrQueueFull, pool.TrySubmit(&Task{ID: "full", F: func() {}}))
+	close(block)
+
+	remaining, err := pool.StopContext(context.Background())
+	assert.NoError(err)
+	assert.Empty(remaining)
+	assert.True(pool.Stopped())
+	assert.Equal(int32(2), c.Load())
+	assert.Equal(ErrPoolStopped, pool.TrySubmit(&Task{ID: "stopped", F: func() {}}))
+	assert.Equal(int64(3), AsExecutor(pool).Stats().TasksSubmitted)
+}
@@ -17,21 +17,125 @@
 
 package workpool
 
-import "time"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-chassis/foundation/executor"
+)
+
+var (
+	// ErrPoolStopped is returned when a task is submitted to a stopped pool
+	ErrPoolStopped = executor.ErrStopped
+	// ErrQueueFull is returned by TrySubmit when the task queue is full
+	ErrQueueFull = errors.New("workpool: task queue is full")
+	// ErrTaskDiscarded is returned by SubmitAndWaitContext when the pool discarded the task
+	ErrTaskDiscarded = errors.New("workpool: task discarded")
+)
 
 // TODO Task 应该有输入和输出
 type Task struct {
 	ID string // Task ID
 	F  func() // Task执行闭包内容
+	// Run 带上下文的任务内容，设置后代替F执行；
+	// ctx在协程池被强制停止时取消，长时间运行的任务应据此退出
+	Run func(ctx context.Context) error
+	// Timeout 单次执行的超时时间，超时后取消传给Run的ctx，0表示不限制
+	Timeout time.Duration
+	// Retry 失败后的重试策略，为空时不重试
+	Retry *RetryPolicy
+	// OnDiscard 任务未执行就被协程池丢弃时调用：RejectDiscardOldest丢弃最早的任务，
+	// 或StopContext超时后作为未执行任务返回
+	OnDiscard func()
+}
+
+// run executes the task, retrying it if the task has a retry policy
+func (t *Task) run(ctx context.Context) error {
+	if t.Retry != nil {
+		return t.runWithRetry(ctx)
+	}
+	return t.runOnce(ctx)
+}
+
+// runOnce executes Run if it is set, otherwise F
+func (t *Task) runOnce(ctx context.Context) error {
+	if t.Run != nil {
+		if t.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+			defer cancel()
+		}
+		return t.Run(ctx)
+	}
+	t.F()
+	return nil
+}
+
+// waitable wraps task so that its error is sent to the returned channel once it finished,
+// a panic is sent as an error and then re-panicked for the pool to recover it,
+// ErrTaskDiscarded is sent if the pool discards the task
+func waitable(task *Task) (*Task, <-chan error) {
+	errCh := make(chan error, 1)
+	// only the first result is kept, a task returned by StopContext may be submitted again
+	send := func(err error) {
+		select {
+		case errCh <- err:
+		default:
+		}
+	}
+	return &Task{
+		ID: task.ID,
+		Run: func(ctx context.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					send(fmt.Errorf("workpool: task panic: %v", r))
+					panic(r)
+				}
+				send(err)
+			}()
+			return task.run(ctx)
+		},
+		OnDiscard: func() {
+			task.discarded()
+			send(ErrTaskDiscarded)
+		},
+	}, errCh
+}
+
+// discarded calls OnDiscard if it is set
+func (t *Task) discarded() {
+	if t.OnDiscard != nil {
+		t.OnDiscard()
+	}
+}
+
+// waitTask waits for the result of a waitable task until ctx is done
+func waitTask(ctx context.Context, errCh <-chan error) error {
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // 开辟一个协程池：当有任务提交时，提交到协程池中运行；如果协程池都在工作，任务挂起
 type Pool interface {
 	// 提交任务
-	Submit(task *Task)        // 提交任务
-	SubmitAndWait(task *Task) // 提交任务并等待其执行
-	Stopped() bool            // 如果协程停止，返回true
-	Stop()                    // 停下来优雅地停止所有的勾当，所有挂起的任务将在退出前完成
+	Submit(task *Task)                                   // 提交任务
+	SubmitContext(ctx context.Context, task *Task) error // 提交任务，队列满时阻塞直到ctx结束或协程池停止
+	TrySubmit(task *Task) error                          // 提交任务，队列满时立即返回ErrQueueFull
+	SubmitAndWait(task *Task)                            // 提交任务并等待其执行
+	Stopped() bool                                       // 如果协程停止，返回true
+	Stop()                                               // 停下来优雅地停止所有的勾当，所有挂起的任务将在退出前完成
+	// SubmitAndWaitContext 提交任务并等待其执行完成，返回任务的错误；
+	// 协程池已停止时返回ErrPoolStopped，ctx结束时不再等待并返回ctx.Err()，已开始的任务继续执行
+	SubmitAndWaitContext(ctx context.Context, task *Task) error
+	// StopContext 与Stop相同，但最多等待到ctx结束：超时后取消正在运行任务的ctx，
+	// 并返回尚未开始执行的任务
+	StopContext(ctx context.Context) ([]*Task, error)
 	//metrics
 }
 
@@ -0,0 +1,404 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	journalFile    = "journal.log"
+	journalTmpFile = "journal.log.tmp"
+
+	// DefaultCompactThreshold is the number of acknowledged tasks in the journal
+	// which triggers a compaction
+	DefaultCompactThreshold = 1024
+
+	// maxJournalRecordSize guards against a corrupted length
+	maxJournalRecordSize = 64 << 20
+
+	journalOpAdd = "add"
+	journalOpAck = "ack"
+)
+
+// ErrHandlerNotFound is returned when submitting a durable task with a handler not registered
+var ErrHandlerNotFound = errors.New("workpool: durable task handler not found")
+
+// Handler runs a durable task from its payload
+type Handler func(ctx context.Context, payload []byte) error
+
+var (
+	handlersMux sync.RWMutex
+	handlers    = make(map[string]Handler)
+)
+
+// RegisterHandler registers a durable task handler by name,
+// handlers must be registered before the journal is opened so that tasks can be replayed
+func RegisterHandler(name string, h Handler) {
+	handlersMux.Lock()
+	handlers[name] = h
+	handlersMux.Unlock()
+}
+
+func getHandler(name string) (Handler, bool) {
+	handlersMux.RLock()
+	h, ok := handlers[name]
+	handlersMux.RUnlock()
+	return h, ok
+}
+
+// journalRecord is one entry of the journal file
+type journalRecord struct {
+	Op      string `json:"op"`
+	ID      string `json:"id"`
+	Handler string `json:"handler,omitempty"`
+	Payload []byte `json:"payload,omitempty"`
+	seq     uint64
+}
+
+// DurableOptions is the configuration of a DurableQueue
+type DurableOptions struct {
+	// CompactThreshold is the number of acknowledged tasks which triggers a compaction
+	CompactThreshold int
+	// NoSync skips fsync after appending a task, tasks may be lost if the machine crashes
+	NoSync bool
+	// Retry 任务失败后的重试策略，重试用尽后任务作为死信确认；
+	// 为空时失败的任务不会确认，在下次打开journal时重新执行
+	Retry *RetryPolicy
+}
+
+type DurableOption func(*DurableOptions)
+
+func WithCompactThreshold(n int) DurableOption {
+	return func(o *DurableOptions) { o.CompactThreshold = n }
+}
+func WithNoSync() DurableOption { return func(o *DurableOptions) { o.NoSync = true } }
+
+// WithDurableRetry retries failed tasks in process, a task whose retries are exhausted
+// is acknowledged and handed to the dead letter handler of the policy or of the pool
+func WithDurableRetry(r *RetryPolicy) DurableOption {
+	return func(o *DurableOptions) { o.Retry = r }
+}
+
+// DurableQueue submits tasks to a pool after appending them to a journal file in a local directory.
+// A task is acknowledged in the journal after its handler succeeds, or after its retries end in a dead letter
+// if WithDurableRetry is set. Tasks not acknowledged, including the failed ones without a retry policy,
+// are submitted again when the journal is opened, so each task runs at least once.
+// Handlers should be idempotent.
+type DurableQueue struct {
+	dir     string
+	pool    Pool
+	opts    DurableOptions
+	mux     sync.Mutex
+	file    *os.File
+	pending map[string]*journalRecord // 未确认的任务
+	acked   int                       // journal中已确认的任务数
+	seq     uint64
+	closed  bool
+}
+
+// OpenDurableQueue opens or creates the journal in dir and submits the tasks not acknowledged to pool
+func OpenDurableQueue(dir string, pool Pool, opts ...DurableOption) (*DurableQueue, error) {
+	o := DurableOptions{CompactThreshold: DefaultCompactThreshold}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	q := &DurableQueue{
+		dir:     dir,
+		pool:    pool,
+		opts:    o,
+		pending: make(map[string]*journalRecord),
+	}
+	if err := q.replay(); err != nil {
+		return nil, err
+	}
+	// start with a compacted journal
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	for _, r := range q.sortedPending() {
+		if err := q.submit(context.Background(), r); err != nil {
+			q.file.Close()
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// replay reads the journal, a truncated or corrupted tail left by a crash is ignored
+func (q *DurableQueue) replay() error {
+	f, err := os.Open(filepath.Join(q.dir, journalFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	for {
+		r, err := readJournalRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			log.Printf("workpool: ignore broken journal tail in %s: %s", q.dir, err)
+			return nil
+		}
+		q.seq++
+		r.seq = q.seq
+		switch r.Op {
+		case journalOpAdd:
+			q.pending[r.ID] = r
+		case journalOpAck:
+			delete(q.pending, r.ID)
+		}
+	}
+}
+
+// Submit appends a task running the handler with payload to the journal and submits it to the pool,
+// it returns the ID of the task
+func (q *DurableQueue) Submit(ctx context.Context, handler string, payload []byte) (string, error) {
+	if _, ok := getHandler(handler); !ok {
+		return "", ErrHandlerNotFound
+	}
+	q.mux.Lock()
+	if q.closed {
+		q.mux.Unlock()
+		return "", ErrPoolStopped
+	}
+	q.seq++
+	r := &journalRecord{
+		Op:      journalOpAdd,
+		ID:      strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatUint(q.seq, 36),
+		Handler: handler,
+		Payload: payload,
+		seq:     q.seq,
+	}
+	err := q.append(r, !q.opts.NoSync)
+	if err == nil {
+		q.pending[r.ID] = r
+	}
+	q.mux.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return r.ID, q.submit(ctx, r)
+}
+
+func (q *DurableQueue) submit(ctx context.Context, r *journalRecord) error {
+	return q.pool.SubmitContext(ctx, &Task{
+		ID: r.ID,
+		Run: func(ctx context.Context) error {
+			h, ok := getHandler(r.Handler)
+			if !ok {
+				// keep it in the journal until the handler is registered
+				log.Printf("workpool: handler %s of durable task %s not found", r.Handler, r.ID)
+				return ErrHandlerNotFound
+			}
+			handle := &Task{
+				ID:    r.ID,
+				Run:   func(ctx context.Context) error { return h(ctx, r.Payload) },
+				Retry: q.opts.Retry,
+			}
+			err := handle.run(ctx)
+			var dl *DeadLetter
+			if err == nil || errors.As(err, &dl) {
+				q.ack(r.ID)
+			}
+			return err
+		},
+	})
+}
+
+// ack marks the task done in the journal, losing an ack only makes the task run again
+func (q *DurableQueue) ack(id string) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.closed {
+		return
+	}
+	if _, ok := q.pending[id]; !ok {
+		return
+	}
+	if err := q.append(&journalRecord{Op: journalOpAck, ID: id}, false); err != nil {
+		log.Printf("workpool: ack durable task %s failed: %s", id, err)
+		return
+	}
+	delete(q.pending, id)
+	q.acked++
+	if q.opts.CompactThreshold > 0 && q.acked >= q.opts.CompactThreshold {
+		if err := q.compact(); err != nil {
+			log.Printf("workpool: compact journal in %s failed: %s", q.dir, err)
+		}
+	}
+}
+
+// Pending returns the IDs of the tasks not acknowledged yet
+func (q *DurableQueue) Pending() []string {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	var ids []string
+	for _, r := range q.sortedPending() {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// Compact rewrites the journal with the tasks not acknowledged only
+func (q *DurableQueue) Compact() error {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.closed {
+		return ErrPoolStopped
+	}
+	return q.compact()
+}
+
+func (q *DurableQueue) compact() error {
+	tmp := filepath.Join(q.dir, journalTmpFile)
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range q.sortedPending() {
+		if err = writeJournalRecord(w, r); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+	if err = os.Rename(tmp, filepath.Join(q.dir, journalFile)); err != nil {
+		return err
+	}
+	q.file, err = os.OpenFile(filepath.Join(q.dir, journalFile), os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	q.acked = 0
+	return nil
+}
+
+func (q *DurableQueue) append(r *journalRecord, sync bool) error {
+	if q.file == nil {
+		return fmt.Errorf("workpool: journal in %s is not open", q.dir)
+	}
+	if err := writeJournalRecord(q.file, r); err != nil {
+		return err
+	}
+	if sync {
+		return q.file.Sync()
+	}
+	return nil
+}
+
+func (q *DurableQueue) sortedPending() []*journalRecord {
+	records := make([]*journalRecord, 0, len(q.pending))
+	for _, r := range q.pending {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+	return records
+}
+
+// Close closes the journal, it does not stop the pool.
+// Tasks finishing after Close are not acknowledged and run again when the journal is opened next time.
+func (q *DurableQueue) Close() error {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	if q.file == nil {
+		return nil
+	}
+	return q.file.Close()
+}
+
+// journal record layout: 4 bytes length, 4 bytes crc32 of the body, json body
+func writeJournalRecord(w io.Writer, r *journalRecord) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(body))
+	copy(buf[8:], body)
+	_, err = w.Write(buf)
+	return err
+}
+
+func readJournalRecord(r io.Reader) (*journalRecord, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("truncated record header")
+		}
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxJournalRecordSize {
+		return nil, errors.New("record too large")
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, errors.New("truncated record body")
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errors.New("record checksum mismatch")
+	}
+	record := &journalRecord{}
+	if err := json.Unmarshal(body, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestDurableQueue(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "journal")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	pool := New("test", WithMaxWorkers(2))
+	defer pool.Stop()
+
+	var ran atomic.Int32
+	done := make(chan string, 10)
+	RegisterHandler("echo", func(ctx context.Context, payload []byte) error {
+		ran.Inc()
+		done <- string(payload)
+		return nil
+	})
+	block := make(chan struct{})
+	RegisterHandler("block", func(ctx context.Context, payload []byte) error {
+		<-block
+		return nil
+	})
+
+	q, err := OpenDurableQueue(dir, pool)
+	assert.NoError(err)
+	_, err = q.Submit(context.Background(), "unknown", nil)
+	assert.Equal(ErrHandlerNotFound, err)
+	_, err = q.Submit(context.Background(), "echo", []byte("hello"))
+	assert.NoError(err)
+	assert.Equal("hello", <-done)
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(q.Pending())
+
+	t.Run("tasks not acknowledged should be replayed after a crash", func(t *testing.T) {
+		id, err := q.Submit(context.Background(), "block", []byte("again"))
+		assert.NoError(err)
+		assert.Equal([]string{id}, q.Pending())
+		// crash before the task finishes
+		assert.NoError(q.Close())
+		close(block)
+
+		// a torn write at the tail
+		f, err := os.OpenFile(filepath.Join(dir, journalFile), os.O_APPEND|os.O_WRONLY, 0600)
+		assert.NoError(err)
+		f.Write([]byte{0, 0, 1})
+		f.Close()
+
+		RegisterHandler("block", func(ctx context.Context, payload []byte) error {
+			done <- string(payload)
+			return nil
+		})
+		q, err = OpenDurableQueue(dir, pool)
+		assert.NoError(err)
+		assert.Equal("again", <-done)
+		time.Sleep(10 * time.Millisecond)
+		assert.Empty(q.Pending())
+	})
+
+	t.Run("failed tasks should stay pending and be replayed", func(t *testing.T) {
+		var calls atomic.Int32
+		RegisterHandler("fail", func(ctx context.Context, payload []byte) error {
+			defer func() { done <- string(payload) }()
+			if calls.Inc() == 1 {
+				return errors.New("fail")
+			}
+			return nil
+		})
+		id, err := q.Submit(context.Background(), "fail", []byte("x"))
+		assert.NoError(err)
+		<-done
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal([]string{id}, q.Pending())
+		assert.NoError(q.Close())
+
+		q, err = OpenDurableQueue(dir, pool)
+		assert.NoError(err)
+		assert.Equal("x", <-done)
+		time.Sleep(10 * time.Millisecond)
+		assert.Empty(q.Pending())
+	})
+
+	t.Run("journal should be compacted", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			_, err := q.Submit(context.Background(), "echo", []byte("x"))
+			assert.NoError(err)
+			<-done
+		}
+		time.Sleep(10 * time.Millisecond)
+		info, err := os.Stat(filepath.Join(dir, journalFile))
+		assert.NoError(err)
+		assert.True(info.Size() > 0)
+		assert.NoError(q.Compact())
+		info, err = os.Stat(filepath.Join(dir, journalFile))
+		assert.NoError(err)
+		assert.Equal(int64(0), info.Size())
+		assert.NoError(q.Close())
+
+		q, err = OpenDurableQueue(dir, pool, WithCompactThreshold(2), WithNoSync())
+		assert.NoError(err)
+		for i := 0; i < 3; i++ {
+			_, err := q.Submit(context.Background(), "echo", []byte("y"))
+			assert.NoError(err)
+			<-done
+		}
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(q.Close())
+		_, err = q.Submit(context.Background(), "echo", nil)
+		assert.Equal(ErrPoolStopped, err)
+	})
+	assert.Equal(int32(9), ran.Load())
+}
+
+func TestDurableQueue_Retry(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "journal")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	pool := New("test", WithMaxWorkers(2))
+	defer pool.Stop()
+
+	var calls atomic.Int32
+	RegisterHandler("always-fail", func(ctx context.Context, payload []byte) error {
+		calls.Inc()
+		return errors.New("fail")
+	})
+	dead := make(chan *DeadLetter, 1)
+	q, err := OpenDurableQueue(dir, pool, WithDurableRetry(&RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     testBackoff,
+		DeadLetter:  func(d *DeadLetter) { dead <- d },
+	}))
+	assert.NoError(err)
+	defer q.Close()
+	id, err := q.Submit(context.Background(), "always-fail", []byte("x"))
+	assert.NoError(err)
+	select {
+	case d := <-dead:
+		assert.Equal(id, d.TaskID)
+		assert.Equal(3, d.Attempts)
+	case <-time.After(time.Second):
+		t.Fatal("no dead letter")
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(int32(3), calls.Load())
+	assert.Empty(q.Pending())
+}
@@ -0,0 +1,165 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workpool
+
+import (
+	"context"
+	"errors"
+	"log"
+	"runtime"
+	"time"
+)
+
+const (
+	// DefaultIdleTimeout 空闲worker默认回收时间
+	DefaultIdleTimeout = 60 * time.Second
+	// DefaultQueueSize Task队列默认长度
+	DefaultQueueSize = 8
+)
+
+// RejectPolicy decides what to do with a submitted task when the task queue is full
+type RejectPolicy int
+
+const (
+	// RejectBlock blocks the submitter until there is room in the queue, this is the default policy
+	RejectBlock RejectPolicy = iota
+	// RejectAbort drops the task, SubmitContext returns ErrQueueFull
+	RejectAbort
+	// RejectCallerRuns runs the task in the submitter's goroutine
+	RejectCallerRuns
+	// RejectDiscardOldest drops the oldest task in the queue to make room for the new one
+	RejectDiscardOldest
+)
+
+// Options is the configuration of a worker pool
+type Options struct {
+	MaxWorkers  int           // 最大worker数
+	MinWorkers  int           // 常驻worker数，不会因空闲被回收
+	IdleTimeout time.Duration // 空闲worker回收时间
+	QueueSize   int           // Task队列长度
+	// OnWorkerStart runs in the worker goroutine when the worker starts
+	OnWorkerStart func()
+	// BeforeTask and AfterTask run in the worker goroutine around each task
+	BeforeTask   func(task *Task)
+	AfterTask    func(task *Task)
+	RejectPolicy RejectPolicy
+	// RecoverFunc runs after recovering a panic of a task
+	RecoverFunc func(r interface{})
+	// WorkStealing gives every worker its own task queue of QueueSize,
+	// idle workers steal tasks from busy ones instead of waiting on one dispatcher
+	WorkStealing bool
+	// DeadLetter receives tasks whose retries are exhausted,
+	// unless the task's RetryPolicy has its own handler
+	DeadLetter func(d *DeadLetter)
+	// OnDiscard runs after the task's own OnDiscard for every task the pool drops without running it
+	OnDiscard func(task *Task)
+}
+
+type Option func(*Options)
+
+func WithMaxWorkers(n int) Option              { return func(o *Options) { o.MaxWorkers = n } }
+func WithMinWorkers(n int) Option              { return func(o *Options) { o.MinWorkers = n } }
+func WithIdleTimeout(d time.Duration) Option   { return func(o *Options) { o.IdleTimeout = d } }
+func WithQueueSize(n int) Option               { return func(o *Options) { o.QueueSize = n } }
+func WithWorkerStartHook(f func()) Option      { return func(o *Options) { o.OnWorkerStart = f } }
+func WithBeforeTask(f func(task *Task)) Option { return func(o *Options) { o.BeforeTask = f } }
+func WithAfterTask(f func(task *Task)) Option  { return func(o *Options) { o.AfterTask = f } }
+func WithRejectPolicy(p RejectPolicy) Option   { return func(o *Options) { o.RejectPolicy = p } }
+func WithWorkStealing() Option                 { return func(o *Options) { o.WorkStealing = true } }
+func WithRecoverFunc(f func(r interface{})) Option {
+	return func(o *Options) { o.RecoverFunc = f }
+}
+func WithDeadLetter(f func(d *DeadLetter)) Option {
+	return func(o *Options) { o.DeadLetter = f }
+}
+func WithDiscardHook(f func(task *Task)) Option {
+	return func(o *Options) { o.OnDiscard = f }
+}
+
+func toOptions(opts ...Option) Options {
+	o := Options{
+		MaxWorkers:  runtime.NumCPU(),
+		IdleTimeout: DefaultIdleTimeout,
+		QueueSize:   DefaultQueueSize,
+		RecoverFunc: func(r interface{}) {
+			log.Println("workpool recover:", r)
+		},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.MaxWorkers < 1 {
+		o.MaxWorkers = 1
+	}
+	if o.MinWorkers < 0 {
+		o.MinWorkers = 0
+	}
+	if o.MinWorkers > o.MaxWorkers {
+		o.MinWorkers = o.MaxWorkers
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = DefaultIdleTimeout
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = DefaultQueueSize
+	}
+	return o
+}
+
+// runTask runs the task with hooks in the current goroutine,
+// it returns true if the task panicked
+func (o *Options) runTask(ctx context.Context, task *Task) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			if o.RecoverFunc != nil {
+				o.RecoverFunc(r)
+			}
+		}
+		if o.AfterTask != nil {
+			o.AfterTask(task)
+		}
+	}()
+	if o.BeforeTask != nil {
+		o.BeforeTask(task)
+	}
+	deliverDeadLetter(task.run(ctx), o.DeadLetter)
+	return false
+}
+
+// discard tells the task and the OnDiscard hook that the pool will not run the task
+func (o *Options) discard(task *Task) {
+	task.discarded()
+	if o.OnDiscard != nil {
+		o.OnDiscard(task)
+	}
+}
+
+// deliverDeadLetter hands the dead letter wrapped in err to the task's own handler,
+// or to fallback if the task has none
+func deliverDeadLetter(err error, fallback func(d *DeadLetter)) {
+	var d *DeadLetter
+	if !errors.As(err, &d) {
+		return
+	}
+	if d.Task != nil && d.Task.Retry != nil && d.Task.Retry.DeadLetter != nil {
+		d.Task.Retry.DeadLetter(d)
+	} else if fallback != nil {
+		fallback(d)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func Test_NewWithOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("min workers should be created and kept alive", func(t *testing.T) {
+		var started atomic.Int32
+		pool := New("test", WithMaxWorkers(4), WithMinWorkers(2),
+			WithIdleTimeout(10*time.Millisecond),
+			WithWorkerStartHook(func() { started.Inc() }))
+		defer pool.Stop()
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(int32(2), pool.(*workerPool).workersAlive.Load())
+		assert.Equal(int32(2), started.Load())
+	})
+
+	t.Run("task hooks should run around each task", func(t *testing.T) {
+		var before, after atomic.Int32
+		pool := New("test", WithMaxWorkers(2),
+			WithBeforeTask(func(task *Task) { before.Inc() }),
+			WithAfterTask(func(task *Task) { after.Inc() }))
+		for i := 0; i < 10; i++ {
+			pool.Submit(&Task{ID: "hook", F: func() {}})
+		}
+		pool.Stop()
+		assert.Equal(int32(10), before.Load())
+		assert.Equal(int32(10), after.Load())
+	})
+
+	t.Run("abort policy should reject tasks when queue is full", func(t *testing.T) {
+		block := make(chan struct{})
+		pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithRejectPolicy(RejectAbort))
+		pool.Submit(&Task{ID: "block", F: func() { <-block }})
+		var err error
+		for i := 0; i < 3 && err == nil; i++ {
+			err = pool.SubmitContext(context.Background(), &Task{ID: "abort", F: func() {}})
+		}
+		assert.Equal(ErrQueueFull, err)
+		close(block)
+		pool.Stop()
+	})
+
+	t.Run("caller runs policy should run tasks in the caller goroutine", func(t *testing.T) {
+		block := make(chan struct{})
+		pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithRejectPolicy(RejectCallerRuns))
+		pool.Submit(&Task{ID: "block", F: func() { <-block }})
+		var c atomic.Int32
+		for i := 0; i < 5; i++ {
+			pool.Submit(&Task{ID: "caller", F: func() { c.Inc() }})
+		}
+		assert.True(c.Load() >= 3)
+		close(block)
+		pool.Stop()
+		assert.Equal(int32(5), c.Load())
+	})
+
+	t.Run("discard oldest policy should keep the newest tasks", func(t *testing.T) {
+		block := make(chan struct{})
+		pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithRejectPolicy(RejectDiscardOldest))
+		pool.Submit(&Task{ID: "block", F: func() { <-block }})
+		var last atomic.String
+		for _, id := range []string{"1", "2", "3", "4"} {
+			id := id
+			pool.Submit(&Task{ID: id, F: func() { last.Store(id) }})
+		}
+		close(block)
+		pool.Stop()
+		assert.Equal("4", last.Load())
+	})
+}
+
+func Test_DiscardHook(t *testing.T) {
+	for name, stealing := range map[string]bool{"worker": false, "stealing": true} {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			opts := []Option{WithMaxWorkers(1), WithQueueSize(1), WithRejectPolicy(RejectDiscardOldest)}
+			if stealing {
+				opts = append(opts, WithWorkStealing())
+			}
+			var hooked, own atomic.Int32
+			pool := New("test", append(opts, WithDiscardHook(func(task *Task) { hooked.Inc() }))...)
+			started, block := make(chan struct{}), make(chan struct{})
+			pool.Submit(&Task{ID: "block", F: func() {
+				close(started)
+				<-block
+			}})
+			<-started
+			for i := 0; i < 3; i++ {
+				pool.Submit(&Task{ID: strconv.Itoa(i), F: func() {}, OnDiscard: func() { own.Inc() }})
+			}
+
+			// tasks returned by StopContext are discarded too
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			remaining, err := pool.StopContext(ctx)
+			assert.Equal(context.DeadlineExceeded, err)
+			close(block)
+			assert.NotEmpty(remaining)
+			assert.Equal(int32(3), own.Load())
+			assert.Equal(hooked.Load(), int32(AsExecutor(pool).Stats().TasksDiscarded))
+			assert.Equal(int32(3), hooked.Load())
+		})
+	}
+
+	t.Run("submit and wait", func(t *testing.T) {
+		pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithRejectPolicy(RejectDiscardOldest), WithWorkStealing())
+		defer pool.Stop()
+		started, block := make(chan struct{}), make(chan struct{})
+		defer close(block)
+		pool.Submit(&Task{ID: "block", F: func() {
+			close(started)
+			<-block
+		}})
+		<-started
+		waited := make(chan error, 1)
+		go func() {
+			waited <- pool.SubmitAndWaitContext(context.Background(), &Task{ID: "wait", F: func() {}})
+		}()
+		time.Sleep(20 * time.Millisecond)
+		pool.Submit(&Task{ID: "newer", F: func() {}})
+		assert.Equal(t, ErrTaskDiscarded, <-waited)
+	})
+}
@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workpool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// TaskState is the final state of a task run by DAG or a batch
+type TaskState int
+
+const (
+	TaskPending   TaskState = iota // 未执行
+	TaskSucceeded                  // 执行成功
+	TaskFailed                     // 执行失败
+	TaskSkipped                    // 依赖的任务未成功，跳过执行
+	TaskCancelled                  // ctx结束或协程池停止，未能执行
+)
+
+func (s TaskState) String() string {
+	switch s {
+	case TaskSucceeded:
+		return "succeeded"
+	case TaskFailed:
+		return "failed"
+	case TaskSkipped:
+		return "skipped"
+	case TaskCancelled:
+		return "cancelled"
+	}
+	return "pending"
+}
+
+// TaskOutcome is the result of one task
+type TaskOutcome struct {
+	ID    string
+	State TaskState
+	Err   error
+	Start time.Time
+	End   time.Time
+	// claimed 任务开始执行或已被放弃时置位，二者只会发生一个
+	claimed atomic.Bool
+}
+
+// giveUp marks the outcome cancelled with err if the task has not started yet,
+// it returns false if the task started or was given up before
+func (o *TaskOutcome) giveUp(err error) bool {
+	if !o.claimed.CAS(false, true) {
+		return false
+	}
+	o.State, o.Err = TaskCancelled, err
+	return true
+}
+
+// runWithOutcome runs task with a context done when either ctx or poolCtx is done,
+// and records the outcome before calling done. A task is not run if ctx is already done.
+// A task given up meanwhile is neither run nor reported to done.
+// A panic is recorded as a failure and then re-panicked for the pool to recover it.
+func runWithOutcome(ctx, poolCtx context.Context, task *Task, o *TaskOutcome, done func()) error {
+	if !o.claimed.CAS(false, true) {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			o.State, o.Err = TaskFailed, fmt.Errorf("workpool: task panic: %v", r)
+			done()
+			panic(r)
+		}
+		done()
+	}()
+	if err := ctx.Err(); err != nil {
+		o.State, o.Err = TaskCancelled, err
+		return err
+	}
+	runCtx, cancel := mergeContext(ctx, poolCtx)
+	defer cancel()
+	o.Start = time.Now()
+	err := task.run(runCtx)
+	o.End = time.Now()
+	if err != nil {
+		o.State, o.Err = TaskFailed, err
+	} else {
+		o.State = TaskSucceeded
+	}
+	return err
+}
+
+// firstError returns the error of the first failed task,
+// or of the first task not succeeded if none failed
+func firstError(outcomes []*TaskOutcome) error {
+	var first *TaskOutcome
+	for _, o := range outcomes {
+		if o.State == TaskFailed {
+			first = o
+			break
+		}
+		if o.State != TaskSucceeded && first == nil {
+			first = o
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return fmt.Errorf("workpool: task %s %s: %w", first.ID, first.State, first.Err)
+}
+
+// mergeContext returns a context which is done when either parent is done
+func mergeContext(a, b context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(a)
+	if b == nil || b.Done() == nil {
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-b.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
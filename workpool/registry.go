@@ -0,0 +1,53 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrPoolNotFound is returned when there is no pool registered with the name
+var ErrPoolNotFound = errors.New("workpool: pool not found")
+
+var (
+	poolsMux sync.RWMutex
+	pools    = make(map[string]Pool)
+)
+
+// Register makes the pool available by name to helpers like Map and ForEach,
+// a pool registered with the same name is replaced
+func Register(name string, pool Pool) {
+	poolsMux.Lock()
+	pools[name] = pool
+	poolsMux.Unlock()
+}
+
+// Unregister removes the pool registered with the name
+func Unregister(name string) {
+	poolsMux.Lock()
+	delete(pools, name)
+	poolsMux.Unlock()
+}
+
+// GetPool returns the pool registered with the name
+func GetPool(name string) (Pool, bool) {
+	poolsMux.RLock()
+	pool, ok := pools[name]
+	poolsMux.RUnlock()
+	return pool, ok
+}
@@ -0,0 +1,148 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workpool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+)
+
+// defaultRetryBackoff keeps the worker waiting for at most 200ms between two attempts
+var defaultRetryBackoff = &backoff.PowerBackoff{InitDelay: 10 * time.Millisecond, MaxDelay: 200 * time.Millisecond, Factor: 2}
+
+// RetryPolicy 任务失败后的重试策略；重试在同一个worker中进行，
+// 等待退避时间期间该worker不会执行其他任务，退避时间应尽量短
+type RetryPolicy struct {
+	// MaxAttempts 最大执行次数，包括第一次执行
+	MaxAttempts int
+	// Backoff 计算第n次重试前的等待时间，为空时从10ms开始翻倍，最长200ms
+	Backoff backoff.Backoff
+	// Retryable decides whether err is worth another attempt, nil retries every error
+	Retryable func(err error) bool
+	// DeadLetter receives the task when it fails for the last time,
+	// it overrides the dead letter handler of the pool
+	DeadLetter func(d *DeadLetter)
+}
+
+func (r *RetryPolicy) backoff() backoff.Backoff {
+	if r.Backoff != nil {
+		return r.Backoff
+	}
+	return defaultRetryBackoff
+}
+
+// DeadLetter records a task whose retries are exhausted, it is returned as
+// the error of the task so that DAG and batch outcomes keep the error history
+type DeadLetter struct {
+	TaskID   string
+	Task     *Task
+	Attempts int
+	Errors   []error // 每次执行的错误，按执行顺序排列
+}
+
+func (d *DeadLetter) Error() string {
+	return fmt.Sprintf("workpool: task %s failed after %d attempts: %v", d.TaskID, d.Attempts, d.Unwrap())
+}
+
+// Unwrap returns the error of the last attempt
+func (d *DeadLetter) Unwrap() error {
+	if len(d.Errors) == 0 {
+		return nil
+	}
+	return d.Errors[len(d.Errors)-1]
+}
+
+// runWithRetry runs the task until it succeeds or the retry policy gives up,
+// each attempt is bounded by the task's Timeout
+func (t *Task) runWithRetry(ctx context.Context) error {
+	var errs []error
+	for attempt := 1; ; attempt++ {
+		err := t.runOnce(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+		if attempt >= t.Retry.MaxAttempts || ctx.Err() != nil ||
+			(t.Retry.Retryable != nil && !t.Retry.Retryable(err)) {
+			break
+		}
+		if !sleepContext(ctx, t.Retry.backoff().Delay(attempt-1)) {
+			break
+		}
+	}
+	return &DeadLetter{TaskID: t.ID, Task: t, Attempts: len(errs), Errors: errs}
+}
+
+// sleepContext waits for d, it returns false if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// DeadLetterQueue keeps the latest dead letters in memory,
+// pass its Put method to WithDeadLetter or RetryPolicy.DeadLetter
+type DeadLetterQueue struct {
+	mu    sync.Mutex
+	size  int
+	items []*DeadLetter
+}
+
+// NewDeadLetterQueue creates a queue holding at most size dead letters,
+// the oldest one is dropped when the queue is full
+func NewDeadLetterQueue(size int) *DeadLetterQueue {
+	if size < 1 {
+		size = 1
+	}
+	return &DeadLetterQueue{size: size}
+}
+
+// Put adds a dead letter to the queue
+func (q *DeadLetterQueue) Put(d *DeadLetter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) >= q.size {
+		q.items = q.items[1:]
+	}
+	q.items = append(q.items, d)
+}
+
+// Len returns the number of dead letters in the queue
+func (q *DeadLetterQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Drain removes and returns all dead letters, oldest first
+func (q *DeadLetterQueue) Drain() []*DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
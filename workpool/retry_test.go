@@ -0,0 +1,160 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workpool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-chassis/foundation/backoff"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+var testBackoff = &backoff.PowerBackoff{InitDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Factor: 2}
+
+func TestTaskRetry(t *testing.T) {
+	assert := assert.New(t)
+	dlq := NewDeadLetterQueue(10)
+	pool := New("test", WithMaxWorkers(2), WithDeadLetter(dlq.Put))
+	defer pool.Stop()
+
+	t.Run("succeed after retries", func(t *testing.T) {
+		var attempts atomic.Int32
+		done := make(chan struct{})
+		pool.Submit(&Task{
+			ID: "flaky",
+			Run: func(ctx context.Context) error {
+				if attempts.Inc() < 3 {
+					return errors.New("not yet")
+				}
+				close(done)
+				return nil
+			},
+			Retry: &RetryPolicy{MaxAttempts: 5, Backoff: testBackoff},
+		})
+		<-done
+		assert.Equal(int32(3), attempts.Load())
+	})
+	t.Run("dead letter keeps error history", func(t *testing.T) {
+		var attempts atomic.Int32
+		pool.SubmitAndWait(&Task{
+			ID: "broken",
+			Run: func(ctx context.Context) error {
+				return errors.New("attempt " + string(rune('0'+attempts.Inc())))
+			},
+			Retry: &RetryPolicy{MaxAttempts: 3, Backoff: testBackoff},
+		})
+		time.Sleep(10 * time.Millisecond)
+		letters := dlq.Drain()
+		assert.Len(letters, 1)
+		d := letters[0]
+		assert.Equal("broken", d.TaskID)
+		assert.Equal(3, d.Attempts)
+		assert.Len(d.Errors, 3)
+		assert.EqualError(d.Errors[0], "attempt 1")
+		assert.EqualError(d.Unwrap(), "attempt 3")
+	})
+	t.Run("not retryable error", func(t *testing.T) {
+		var attempts atomic.Int32
+		fatal := errors.New("fatal")
+		got := make(chan *DeadLetter, 1)
+		pool.Submit(&Task{
+			ID: "fatal",
+			Run: func(ctx context.Context) error {
+				attempts.Inc()
+				return fatal
+			},
+			Retry: &RetryPolicy{
+				MaxAttempts: 5,
+				Backoff:     testBackoff,
+				Retryable:   func(err error) bool { return err != fatal },
+				DeadLetter:  func(d *DeadLetter) { got <- d },
+			},
+		})
+		d := <-got
+		assert.Equal(int32(1), attempts.Load())
+		assert.True(errors.Is(d, fatal))
+		assert.Equal(0, dlq.Len())
+	})
+}
+
+func TestTaskTimeout(t *testing.T) {
+	assert := assert.New(t)
+	dlq := NewDeadLetterQueue(10)
+	pool := New("test", WithMaxWorkers(1), WithDeadLetter(dlq.Put))
+	defer pool.Stop()
+
+	var attempts atomic.Int32
+	start := time.Now()
+	pool.SubmitAndWait(&Task{
+		ID: "slow",
+		Run: func(ctx context.Context) error {
+			attempts.Inc()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		},
+		Timeout: 20 * time.Millisecond,
+		Retry:   &RetryPolicy{MaxAttempts: 2, Backoff: testBackoff},
+	})
+	assert.Less(int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.Equal(int32(2), attempts.Load())
+	time.Sleep(10 * time.Millisecond)
+	letters := dlq.Drain()
+	assert.Len(letters, 1)
+	assert.True(errors.Is(letters[0], context.DeadlineExceeded))
+}
+
+func TestDeadLetterQueue(t *testing.T) {
+	assert := assert.New(t)
+	q := NewDeadLetterQueue(2)
+	for _, id := range []string{"a", "b", "c"} {
+		q.Put(&DeadLetter{TaskID: id})
+	}
+	assert.Equal(2, q.Len())
+	letters := q.Drain()
+	assert.Equal("b", letters[0].TaskID)
+	assert.Equal("c", letters[1].TaskID)
+	assert.Equal(0, q.Len())
+}
+
+func TestRetryPolicy_DefaultBackoff(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(1))
+	defer pool.Stop()
+
+	var attempts atomic.Int32
+	start := time.Now()
+	pool.SubmitAndWait(&Task{
+		ID: "failing",
+		Run: func(ctx context.Context) error {
+			attempts.Inc()
+			return errors.New("fail")
+		},
+		Retry: &RetryPolicy{MaxAttempts: 4},
+	})
+	// the worker must not be held for seconds by a failing task
+	assert.Less(int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.Equal(int32(4), attempts.Load())
+}
@@ -0,0 +1,372 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTaskScheduled is returned when a task with the same ID is already scheduled
+	ErrTaskScheduled = errors.New("workpool: task already scheduled")
+	// ErrSchedulerStopped is returned when scheduling on a stopped scheduler
+	ErrSchedulerStopped = errors.New("workpool: scheduler is stopped")
+	// ErrInvalidSchedule is returned for a nil task, an empty task ID or a non-positive period
+	ErrInvalidSchedule = errors.New("workpool: invalid schedule")
+)
+
+// queueFullRetry is the delay before a run which found the pool full is submitted again
+const queueFullRetry = 50 * time.Millisecond
+
+// MissedRunPolicy decides what to do with the runs of a periodic task
+// which were missed, e.g. because the pool's queue was full for a while.
+// A one-shot task is always retried until the pool accepts it.
+type MissedRunPolicy int
+
+const (
+	// MissedRunSkip drops the missed runs and waits for the next one in the future, this is the default policy
+	MissedRunSkip MissedRunPolicy = iota
+	// MissedRunCatchUp submits every missed run as soon as possible,
+	// a run which found the pool full is submitted again shortly
+	MissedRunCatchUp
+)
+
+type scheduleKind int
+
+const (
+	scheduleOnce scheduleKind = iota
+	scheduleFixedRate
+	scheduleFixedDelay
+	scheduleCron
+)
+
+// ScheduleOptions is the configuration of a scheduled task
+type ScheduleOptions struct {
+	// Jitter adds a random delay in [0, Jitter) to every run
+	Jitter    time.Duration
+	MissedRun MissedRunPolicy
+}
+
+type ScheduleOption func(*ScheduleOptions)
+
+func WithJitter(d time.Duration) ScheduleOption { return func(o *ScheduleOptions) { o.Jitter = d } }
+func WithMissedRunPolicy(p MissedRunPolicy) ScheduleOption {
+	return func(o *ScheduleOptions) { o.MissedRun = p }
+}
+
+type scheduledTask struct {
+	task      *Task
+	kind      scheduleKind
+	period    time.Duration
+	cron      *CronSchedule
+	opts      ScheduleOptions
+	at        time.Time // 计划执行时间
+	next      time.Time // 实际执行时间，at加上jitter
+	index     int
+	cancelled bool
+}
+
+// schedule sets the next planned time, it returns false if there is no next run
+func (st *scheduledTask) schedule(at time.Time) bool {
+	if at.IsZero() {
+		return false
+	}
+	st.at, st.next = at, at
+	if st.opts.Jitter > 0 {
+		st.next = at.Add(time.Duration(rand.Int63n(int64(st.opts.Jitter))))
+	}
+	return true
+}
+
+// nextRun returns the planned time of the run after the one at st.at,
+// or zero time if the task is not periodic
+func (st *scheduledTask) nextRun(now time.Time) time.Time {
+	switch st.kind {
+	case scheduleFixedRate:
+		next := st.at.Add(st.period)
+		if next.After(now) || st.opts.MissedRun == MissedRunCatchUp {
+			return next
+		}
+		missed := now.Sub(st.at) / st.period
+		return st.at.Add((missed + 1) * st.period)
+	case scheduleCron:
+		next := st.cron.Next(st.at)
+		if next.After(now) || st.opts.MissedRun == MissedRunCatchUp {
+			return next
+		}
+		return st.cron.Next(now)
+	}
+	return time.Time{}
+}
+
+type scheduleQueue []*scheduledTask
+
+func (q scheduleQueue) Len() int           { return len(q) }
+func (q scheduleQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q scheduleQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i]; q[i].index = i; q[j].index = j }
+func (q *scheduleQueue) Push(x interface{}) {
+	st := x.(*scheduledTask)
+	st.index = len(*q)
+	*q = append(*q, st)
+}
+func (q *scheduleQueue) Pop() interface{} {
+	old := *q
+	st := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return st
+}
+
+// Scheduler submits tasks into a pool at a future time or periodically.
+// It runs one timer goroutine, the tasks themselves are executed by the pool's workers.
+// Runs are submitted with TrySubmit, so a full pool never blocks the other schedules.
+type Scheduler struct {
+	pool    Pool
+	mux     sync.Mutex
+	tasks   map[string]*scheduledTask
+	queue   scheduleQueue
+	wakeup  chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// NewScheduler creates a scheduler which submits tasks into pool
+func NewScheduler(pool Pool) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		pool:    pool,
+		tasks:   make(map[string]*scheduledTask),
+		wakeup:  make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		stopped: make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// ScheduleOnce submits the task once after delay
+func (s *Scheduler) ScheduleOnce(task *Task, delay time.Duration, opts ...ScheduleOption) error {
+	return s.add(&scheduledTask{task: task, kind: scheduleOnce}, time.Now().Add(delay), opts)
+}
+
+// ScheduleAtFixedRate submits the task after initialDelay and then every period,
+// no matter how long each run takes
+func (s *Scheduler) ScheduleAtFixedRate(task *Task, initialDelay, period time.Duration, opts ...ScheduleOption) error {
+	if period <= 0 {
+		return ErrInvalidSchedule
+	}
+	return s.add(&scheduledTask{task: task, kind: scheduleFixedRate, period: period}, time.Now().Add(initialDelay), opts)
+}
+
+// ScheduleWithFixedDelay submits the task after initialDelay,
+// and then delay after each run is finished
+func (s *Scheduler) ScheduleWithFixedDelay(task *Task, initialDelay, delay time.Duration, opts ...ScheduleOption) error {
+	if delay <= 0 {
+		return ErrInvalidSchedule
+	}
+	return s.add(&scheduledTask{task: task, kind: scheduleFixedDelay, period: delay}, time.Now().Add(initialDelay), opts)
+}
+
+// ScheduleCron submits the task at the times matching the cron expression, see ParseCron
+func (s *Scheduler) ScheduleCron(task *Task, spec string, opts ...ScheduleOption) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	return s.add(&scheduledTask{task: task, kind: scheduleCron, cron: cron}, cron.Next(time.Now()), opts)
+}
+
+func (s *Scheduler) add(st *scheduledTask, at time.Time, opts []ScheduleOption) error {
+	if st.task == nil || st.task.ID == "" {
+		return ErrInvalidSchedule
+	}
+	for _, opt := range opts {
+		opt(&st.opts)
+	}
+	s.mux.Lock()
+	if s.ctx.Err() != nil {
+		s.mux.Unlock()
+		return ErrSchedulerStopped
+	}
+	if _, ok := s.tasks[st.task.ID]; ok {
+		s.mux.Unlock()
+		return ErrTaskScheduled
+	}
+	if !st.schedule(at) {
+		s.mux.Unlock()
+		return ErrInvalidSchedule
+	}
+	s.tasks[st.task.ID] = st
+	heap.Push(&s.queue, st)
+	s.mux.Unlock()
+	s.notify()
+	return nil
+}
+
+// Cancel stops the future runs of the task, runs already submitted to the pool are not affected.
+// It returns false if there is no such task.
+func (s *Scheduler) Cancel(id string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	st, ok := s.tasks[id]
+	if !ok {
+		return false
+	}
+	st.cancelled = true
+	delete(s.tasks, id)
+	if st.index >= 0 {
+		heap.Remove(&s.queue, st.index)
+	}
+	return true
+}
+
+// Stop stops scheduling any task, it does not stop the pool
+func (s *Scheduler) Stop() {
+	s.mux.Lock()
+	s.cancel()
+	s.mux.Unlock()
+	<-s.stopped
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.stopped)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mux.Lock()
+		var (
+			st   *scheduledTask
+			wait = time.Hour
+		)
+		if len(s.queue) > 0 {
+			if d := time.Until(s.queue[0].next); d > 0 {
+				wait = d
+			} else {
+				st = heap.Pop(&s.queue).(*scheduledTask)
+				st.index = -1
+			}
+		}
+		s.mux.Unlock()
+		if st != nil {
+			s.fire(st)
+			continue
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.wakeup:
+		case <-timer.C:
+		}
+	}
+}
+
+// fire submits one run of the task and plans the next one
+func (s *Scheduler) fire(st *scheduledTask) {
+	task := st.task
+	if st.kind == scheduleFixedDelay {
+		task = &Task{
+			ID: st.task.ID,
+			Run: func(ctx context.Context) error {
+				defer func() {
+					s.reschedule(st, time.Now().Add(st.period))
+				}()
+				return st.task.run(ctx)
+			},
+			// the next run is planned when this one ends, which a discarded run never does
+			OnDiscard: func() {
+				st.task.discarded()
+				s.reschedule(st, time.Now().Add(st.period))
+			},
+		}
+	}
+	err := s.pool.TrySubmit(task)
+	switch {
+	case err == ErrPoolStopped || s.ctx.Err() != nil:
+		s.remove(st)
+	case err == ErrQueueFull && (st.kind == scheduleOnce || st.opts.MissedRun == MissedRunCatchUp):
+		s.retry(st)
+	case st.kind == scheduleFixedDelay:
+		if err != nil {
+			// the run is missed, try again after delay
+			s.reschedule(st, time.Now().Add(st.period))
+		}
+	default:
+		s.reschedule(st, st.nextRun(time.Now()))
+	}
+}
+
+// reschedule puts the task back to the queue, or removes it if there is no next run
+func (s *Scheduler) reschedule(st *scheduledTask, at time.Time) {
+	s.mux.Lock()
+	// a run returned by StopContext and submitted again may end after its discard rescheduled it
+	if st.cancelled || s.ctx.Err() != nil || st.index >= 0 {
+		s.mux.Unlock()
+		return
+	}
+	if !st.schedule(at) {
+		st.cancelled = true
+		delete(s.tasks, st.task.ID)
+		s.mux.Unlock()
+		return
+	}
+	heap.Push(&s.queue, st)
+	s.mux.Unlock()
+	s.notify()
+}
+
+// retry puts the task back to the queue to submit the same run again shortly
+func (s *Scheduler) retry(st *scheduledTask) {
+	s.mux.Lock()
+	if st.cancelled || s.ctx.Err() != nil || st.index >= 0 {
+		s.mux.Unlock()
+		return
+	}
+	st.next = time.Now().Add(queueFullRetry)
+	heap.Push(&s.queue, st)
+	s.mux.Unlock()
+	s.notify()
+}
+
+func (s *Scheduler) remove(st *scheduledTask) {
+	s.mux.Lock()
+	st.cancelled = true
+	if s.tasks[st.task.ID] == st {
+		delete(s.tasks, st.task.ID)
+	}
+	s.mux.Unlock()
+}
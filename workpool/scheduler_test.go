@@ -0,0 +1,174 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func TestScheduler(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(4))
+	defer pool.Stop()
+	s := NewScheduler(pool)
+	defer s.Stop()
+
+	t.Run("schedule once", func(t *testing.T) {
+		done := make(chan time.Time, 1)
+		start := time.Now()
+		err := s.ScheduleOnce(&Task{ID: "once", F: func() { done <- time.Now() }}, 50*time.Millisecond)
+		assert.NoError(err)
+		assert.True((<-done).Sub(start) >= 50*time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		assert.False(s.Cancel("once"))
+	})
+
+	t.Run("schedule at fixed rate and cancel", func(t *testing.T) {
+		var c atomic.Int32
+		task := &Task{ID: "rate", F: func() { c.Inc() }}
+		assert.NoError(s.ScheduleAtFixedRate(task, 0, 20*time.Millisecond))
+		assert.Equal(ErrTaskScheduled, s.ScheduleAtFixedRate(task, 0, 20*time.Millisecond))
+		time.Sleep(110 * time.Millisecond)
+		assert.True(s.Cancel("rate"))
+		n := c.Load()
+		assert.True(n >= 4 && n <= 7, n)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(n, c.Load())
+	})
+
+	t.Run("schedule with fixed delay", func(t *testing.T) {
+		var c atomic.Int32
+		task := &Task{ID: "delay", F: func() {
+			time.Sleep(30 * time.Millisecond)
+			c.Inc()
+		}}
+		assert.NoError(s.ScheduleWithFixedDelay(task, 0, 20*time.Millisecond, WithJitter(time.Millisecond)))
+		time.Sleep(180 * time.Millisecond)
+		assert.True(s.Cancel("delay"))
+		n := c.Load()
+		assert.True(n >= 2 && n <= 4, n)
+	})
+
+	t.Run("invalid schedule", func(t *testing.T) {
+		assert.Equal(ErrInvalidSchedule, s.ScheduleOnce(nil, 0))
+		assert.Equal(ErrInvalidSchedule, s.ScheduleOnce(&Task{F: func() {}}, 0))
+		assert.Equal(ErrInvalidSchedule, s.ScheduleAtFixedRate(&Task{ID: "rate", F: func() {}}, 0, 0))
+		assert.Error(s.ScheduleCron(&Task{ID: "cron", F: func() {}}, "bad"))
+		assert.NoError(s.ScheduleCron(&Task{ID: "cron", F: func() {}}, "@daily"))
+		assert.True(s.Cancel("cron"))
+	})
+}
+
+func TestScheduler_Stop(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(1))
+	defer pool.Stop()
+	s := NewScheduler(pool)
+	var c atomic.Int32
+	assert.NoError(s.ScheduleAtFixedRate(&Task{ID: "rate", F: func() { c.Inc() }}, 0, 10*time.Millisecond))
+	time.Sleep(30 * time.Millisecond)
+	s.Stop()
+	// runs already submitted to the pool are not affected
+	time.Sleep(10 * time.Millisecond)
+	n := c.Load()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(n, c.Load())
+	assert.Equal(ErrSchedulerStopped, s.ScheduleOnce(&Task{ID: "once", F: func() {}}, 0))
+}
+
+func TestScheduler_FullPool(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithWorkStealing())
+	defer pool.Stop()
+	s := NewScheduler(pool)
+	defer s.Stop()
+	started, release := make(chan struct{}), make(chan struct{})
+	pool.Submit(&Task{ID: "block", F: func() {
+		close(started)
+		<-release
+	}})
+	<-started
+	assert.NoError(pool.TrySubmit(&Task{ID: "fill", F: func() {}}))
+
+	var rate atomic.Int32
+	once := make(chan struct{})
+	assert.NoError(s.ScheduleAtFixedRate(&Task{ID: "rate", F: func() { rate.Inc() }}, 0, 10*time.Millisecond))
+	assert.NoError(s.ScheduleOnce(&Task{ID: "once", F: func() { close(once) }}, 0))
+	time.Sleep(60 * time.Millisecond)
+	// the runs of the periodic task found the pool full and were skipped
+	assert.True(s.Cancel("rate"))
+	close(release)
+	select {
+	case <-once:
+	case <-time.After(time.Second):
+		t.Fatal("one-shot task was not retried")
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(int32(0), rate.Load())
+}
+
+func TestScheduler_DiscardedRun(t *testing.T) {
+	assert := assert.New(t)
+	pool := New("test", WithMaxWorkers(1), WithQueueSize(1), WithWorkStealing(), WithRejectPolicy(RejectDiscardOldest))
+	defer pool.Stop()
+	s := NewScheduler(pool)
+	defer s.Stop()
+	started, release := make(chan struct{}), make(chan struct{})
+	pool.Submit(&Task{ID: "block", F: func() {
+		close(started)
+		<-release
+	}})
+	<-started
+
+	var c atomic.Int32
+	assert.NoError(s.ScheduleWithFixedDelay(&Task{ID: "delay", F: func() { c.Inc() }}, 0, 20*time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+	// discards the queued run of the fixed delay task
+	pool.Submit(&Task{ID: "newer", F: func() {}})
+	close(release)
+	time.Sleep(100 * time.Millisecond)
+	assert.True(c.Load() >= 1)
+	assert.True(s.Cancel("delay"))
+}
+
+func TestScheduledTask_NextRun(t *testing.T) {
+	assert := assert.New(t)
+	at := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	now := at.Add(35 * time.Second)
+
+	st := &scheduledTask{kind: scheduleFixedRate, period: 10 * time.Second}
+	st.schedule(at)
+	assert.Equal(at.Add(40*time.Second), st.nextRun(now))
+	st.opts.MissedRun = MissedRunCatchUp
+	assert.Equal(at.Add(10*time.Second), st.nextRun(now))
+
+	cron, _ := ParseCron("* * * * *")
+	st = &scheduledTask{kind: scheduleCron, cron: cron}
+	st.schedule(at)
+	now = at.Add(150 * time.Second)
+	assert.Equal(at.Add(3*time.Minute), st.nextRun(now))
+	st.opts.MissedRun = MissedRunCatchUp
+	assert.Equal(at.Add(time.Minute), st.nextRun(now))
+
+	st = &scheduledTask{kind: scheduleOnce}
+	st.schedule(at)
+	assert.True(st.nextRun(now).IsZero())
+}
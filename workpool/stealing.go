@@ -0,0 +1,448 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// taskDeque is a bounded ring buffer of tasks,
+// the owner worker takes tasks from the front and thieves steal from the back
+type taskDeque struct {
+	mux  sync.Mutex
+	buf  []*Task
+	head int
+	size int
+}
+
+func newTaskDeque(capacity int) *taskDeque {
+	return &taskDeque{buf: make([]*Task, capacity)}
+}
+
+func (d *taskDeque) pushBack(task *Task) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.size == len(d.buf) {
+		return false
+	}
+	d.buf[(d.head+d.size)%len(d.buf)] = task
+	d.size++
+	return true
+}
+
+func (d *taskDeque) popFront() *Task {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.size == 0 {
+		return nil
+	}
+	task := d.buf[d.head]
+	d.buf[d.head] = nil
+	d.head = (d.head + 1) % len(d.buf)
+	d.size--
+	return task
+}
+
+// stealHalf takes half of the tasks, at least one, from the back
+func (d *taskDeque) stealHalf() []*Task {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.size == 0 {
+		return nil
+	}
+	n := (d.size + 1) / 2
+	stolen := make([]*Task, n)
+	for i := n - 1; i >= 0; i-- {
+		d.size--
+		idx := (d.head + d.size) % len(d.buf)
+		stolen[i] = d.buf[idx]
+		d.buf[idx] = nil
+	}
+	return stolen
+}
+
+func (d *taskDeque) len() int {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return d.size
+}
+
+// stealingPool runs a fixed set of worker slots, each with its own task queue.
+// Submitters spread tasks over the queues round robin, a worker takes tasks
+// from its own queue first and steals from the others when it is empty.
+// A worker goroutine is started on demand and exits after being idle for IdleTimeout.
+type stealingPool struct {
+	name           string
+	opts           Options
+	workers        []*stealingWorker
+	next           atomic.Uint32 // round robin cursor of submitters
+	idle           atomic.Int32  // parked worker count
+	idleWake       chan struct{} // wakes any parked worker to steal
+	space          chan struct{} // signals blocked submitters that a task was taken
+	stopCh         chan struct{}
+	stopped        atomic.Bool
+	wg             sync.WaitGroup
+	workersAlive   atomic.Int32
+	workersCreated atomic.Int32
+	workersKilled  atomic.Int32
+	tasksConsumed  atomic.Int32
+	tasksSubmitted atomic.Int32
+	tasksPanicked  atomic.Int32
+	tasksDiscarded atomic.Int32
+	taskCtx        context.Context
+	taskCancel     context.CancelFunc
+}
+
+type stealingWorker struct {
+	id      int
+	pool    *stealingPool
+	deque   *taskDeque
+	running atomic.Bool
+	wake    chan struct{}
+	rand    uint32
+	// overflow 窃取到但放不进自己队列的任务，只由worker自身访问，优先执行
+	overflow []*Task
+}
+
+func newStealingPool(name string, o Options) Pool {
+	taskCtx, taskCancel := context.WithCancel(context.Background())
+	p := &stealingPool{
+		name:       name,
+		opts:       o,
+		workers:    make([]*stealingWorker, o.MaxWorkers),
+		idleWake:   make(chan struct{}, o.MaxWorkers),
+		space:      make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+		taskCtx:    taskCtx,
+		taskCancel: taskCancel,
+	}
+	for i := range p.workers {
+		p.workers[i] = &stealingWorker{
+			id:    i,
+			pool:  p,
+			deque: newTaskDeque(o.QueueSize),
+			wake:  make(chan struct{}, 1),
+			rand:  uint32(i)*2654435761 + 1,
+		}
+	}
+	for i := 0; i < o.MinWorkers; i++ {
+		p.workers[i].start()
+	}
+	return p
+}
+
+// push puts the task into the first queue with room, starting from the round robin cursor
+func (p *stealingPool) push(task *Task) bool {
+	n := uint32(len(p.workers))
+	start := p.next.Inc()
+	for i := uint32(0); i < n; i++ {
+		w := p.workers[(start+i)%n]
+		if w.deque.pushBack(task) {
+			w.signal()
+			return true
+		}
+	}
+	return false
+}
+
+func (p *stealingPool) Submit(task *Task) {
+	_ = p.SubmitContext(context.Background(), task)
+}
+
+func (p *stealingPool) SubmitContext(ctx context.Context, task *Task) error {
+	if task == nil {
+		return nil
+	}
+	if err := p.submit(ctx, task); err != nil {
+		return err
+	}
+	p.tasksSubmitted.Inc()
+	return nil
+}
+
+func (p *stealingPool) submit(ctx context.Context, task *Task) error {
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	if p.push(task) {
+		return nil
+	}
+	switch p.opts.RejectPolicy {
+	case RejectAbort:
+		return ErrQueueFull
+	case RejectCallerRuns:
+		p.runTask(task)
+		return nil
+	case RejectDiscardOldest:
+		for !p.push(task) {
+			// 丢弃最早的任务
+			if old := p.workers[p.next.Load()%uint32(len(p.workers))].deque.popFront(); old != nil {
+				p.discard(old)
+			}
+		}
+		return nil
+	}
+	for {
+		select {
+		case <-p.space:
+		case <-p.stopCh:
+			return ErrPoolStopped
+		case <-ctx.Done():
+			// pass the signal on to the other blocked submitters
+			p.signalSpace()
+			return ctx.Err()
+		}
+		if p.Stopped() {
+			return ErrPoolStopped
+		}
+		if p.push(task) {
+			return nil
+		}
+	}
+}
+
+func (p *stealingPool) TrySubmit(task *Task) error {
+	if task == nil {
+		return nil
+	}
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	if !p.push(task) {
+		return ErrQueueFull
+	}
+	p.tasksSubmitted.Inc()
+	return nil
+}
+
+func (p *stealingPool) SubmitAndWait(task *Task) {
+	_ = p.SubmitAndWaitContext(context.Background(), task)
+}
+
+func (p *stealingPool) SubmitAndWaitContext(ctx context.Context, task *Task) error {
+	if task == nil {
+		return nil
+	}
+	wrapped, errCh := waitable(task)
+	if err := p.SubmitContext(ctx, wrapped); err != nil {
+		return err
+	}
+	return waitTask(ctx, errCh)
+}
+
+func (p *stealingPool) signalSpace() {
+	select {
+	case p.space <- struct{}{}:
+	default:
+	}
+}
+
+// runTask runs the task with hooks in the current goroutine
+func (p *stealingPool) runTask(task *Task) {
+	if p.opts.runTask(p.taskCtx, task) {
+		p.tasksPanicked.Inc()
+	}
+	p.tasksConsumed.Inc()
+}
+
+// discard drops a task which will not be run
+func (p *stealingPool) discard(task *Task) {
+	p.tasksDiscarded.Inc()
+	p.opts.discard(task)
+}
+
+func (p *stealingPool) Stopped() bool {
+	return p.stopped.Load()
+}
+
+// Stop waits for all pending tasks done.
+func (p *stealingPool) Stop() {
+	_, _ = p.StopContext(context.Background())
+}
+
+// StopContext waits for pending tasks until ctx is done.
+// If ctx is done first, the context passed to running tasks is cancelled and
+// the tasks not started yet are returned along with ctx.Err().
+// It returns nothing if the pool is already stopped.
+func (p *stealingPool) StopContext(ctx context.Context) ([]*Task, error) {
+	if p.stopped.Swap(true) {
+		return nil, nil
+	}
+	close(p.stopCh)
+	// workers drain all queues before exiting
+	for _, w := range p.workers {
+		if w.deque.len() > 0 {
+			w.start()
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.wg.Wait()
+		// submitters racing with Stop may have pushed after the workers exited
+		for _, w := range p.workers {
+			for task := w.deque.popFront(); task != nil && ctx.Err() == nil; task = w.deque.popFront() {
+				p.runTask(task)
+			}
+		}
+	}()
+	select {
+	case <-done:
+		if ctx.Err() == nil {
+			p.taskCancel()
+			return nil, nil
+		}
+	case <-ctx.Done():
+	}
+	p.taskCancel()
+	var remaining []*Task
+	for _, w := range p.workers {
+		for task := w.deque.popFront(); task != nil; task = w.deque.popFront() {
+			remaining = append(remaining, task)
+		}
+	}
+	for _, task := range remaining {
+		p.discard(task)
+	}
+	return remaining, ctx.Err()
+}
+
+// signal makes sure there is a goroutine to run the tasks in w's queue
+func (w *stealingWorker) signal() {
+	// after stop the draining workers or Stop itself take care of the queues
+	if !w.pool.Stopped() && w.start() {
+		return
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	// let a parked worker help if w is busy
+	if w.pool.idle.Load() > 0 {
+		select {
+		case w.pool.idleWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// start starts the worker goroutine if it is not running
+func (w *stealingWorker) start() bool {
+	if !w.running.CAS(false, true) {
+		return false
+	}
+	w.pool.wg.Add(1)
+	w.pool.workersAlive.Inc()
+	w.pool.workersCreated.Inc()
+	go w.process()
+	return true
+}
+
+// exit marks the worker not running, it returns false if a task arrived meanwhile
+// and the worker has to keep running
+func (w *stealingWorker) exit() bool {
+	w.running.Store(false)
+	if w.deque.len() > 0 && w.running.CAS(false, true) {
+		return false
+	}
+	w.pool.workersAlive.Dec()
+	w.pool.workersKilled.Inc()
+	return true
+}
+
+// take returns a task from the own queue, or steals from the other workers
+func (w *stealingWorker) take() *Task {
+	if len(w.overflow) > 0 {
+		task := w.overflow[0]
+		w.overflow[0] = nil
+		w.overflow = w.overflow[1:]
+		return task
+	}
+	if task := w.deque.popFront(); task != nil {
+		return task
+	}
+	workers := w.pool.workers
+	n := len(workers)
+	// xorshift, pick a random victim to start with
+	w.rand ^= w.rand << 13
+	w.rand ^= w.rand >> 17
+	w.rand ^= w.rand << 5
+	start := int(w.rand % uint32(n))
+	for i := 0; i < n; i++ {
+		victim := workers[(start+i)%n]
+		if victim == w {
+			continue
+		}
+		if stolen := victim.deque.stealHalf(); len(stolen) > 0 {
+			for _, task := range stolen[1:] {
+				// submitters may have filled the own queue since it was found empty
+				if !w.deque.pushBack(task) {
+					w.overflow = append(w.overflow, task)
+				}
+			}
+			return stolen[0]
+		}
+	}
+	return nil
+}
+
+func (w *stealingWorker) process() {
+	p := w.pool
+	defer p.wg.Done()
+	if p.opts.OnWorkerStart != nil {
+		p.opts.OnWorkerStart()
+	}
+	timer := time.NewTimer(p.opts.IdleTimeout)
+	defer timer.Stop()
+	for {
+		if task := w.take(); task != nil {
+			p.signalSpace()
+			p.runTask(task)
+			continue
+		}
+		if p.Stopped() {
+			if w.exit() {
+				return
+			}
+			continue
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(p.opts.IdleTimeout)
+		p.idle.Inc()
+		select {
+		case <-w.wake:
+		case <-p.idleWake:
+		case <-p.stopCh:
+		case <-timer.C:
+			// 超时, 保留常驻worker
+			if w.id >= p.opts.MinWorkers && w.exit() {
+				p.idle.Dec()
+				return
+			}
+		}
+		p.idle.Dec()
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package workpool
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+)
+
+func Test_StealingPool(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("submit and stop, all tasks finished", func(t *testing.T) {
+		pool := New("test", WithWorkStealing(), WithMaxWorkers(4), WithQueueSize(16))
+		var c atomic.Int32
+		for i := 0; i < 1000; i++ {
+			pool.Submit(&Task{ID: "inc" + strconv.Itoa(i), F: func() { c.Inc() }})
+		}
+		pool.SubmitAndWait(&Task{ID: "wait", F: func() { c.Inc() }})
+		pool.Stop()
+		assert.True(pool.Stopped())
+		assert.Equal(int32(1001), c.Load())
+		assert.Equal(ErrPoolStopped, pool.TrySubmit(&Task{ID: "stopped", F: func() {}}))
+		assert.Equal(int32(0), pool.(*stealingPool).workersAlive.Load())
+	})
+
+	t.Run("idle workers steal tasks queued behind a busy worker", func(t *testing.T) {
+		pool := New("test", WithWorkStealing(), WithMaxWorkers(2), WithQueueSize(16)).(*stealingPool)
+		defer pool.Stop()
+		block := make(chan struct{})
+		started := make(chan struct{})
+		busy := pool.workers[0]
+		assert.True(busy.deque.pushBack(&Task{ID: "block", F: func() {
+			close(started)
+			<-block
+		}}))
+		busy.signal()
+		<-started
+		done := make(chan struct{}, 4)
+		for i := 0; i < 4; i++ {
+			assert.True(busy.deque.pushBack(&Task{ID: "stolen", F: func() { done <- struct{}{} }}))
+		}
+		pool.workers[1].start()
+		for i := 0; i < 4; i++ {
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("tasks of the busy worker should be stolen")
+			}
+		}
+		close(block)
+	})
+
+	t.Run("queue full and stop with deadline", func(t *testing.T) {
+		pool := New("test", WithWorkStealing(), WithMaxWorkers(1), WithQueueSize(2))
+		cancelled := make(chan struct{})
+		started := make(chan struct{})
+		pool.Submit(&Task{ID: "long", Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		}})
+		<-started
+		assert.NoError(pool.TrySubmit(&Task{ID: "1", F: func() {}}))
+		assert.NoError(pool.TrySubmit(&Task{ID: "2", F: func() {}}))
+		assert.Equal(ErrQueueFull, pool.TrySubmit(&Task{ID: "3", F: func() {}}))
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.Equal(context.DeadlineExceeded, pool.SubmitContext(ctx, &Task{ID: "3", F: func() {}}))
+
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		remaining, err := pool.StopContext(ctx)
+		assert.Equal(context.DeadlineExceeded, err)
+		assert.Equal(2, len(remaining))
+		<-cancelled
+	})
+
+	t.Run("idle workers exit except min workers", func(t *testing.T) {
+		pool := New("test", WithWorkStealing(), WithMaxWorkers(4), WithMinWorkers(1),
+			WithIdleTimeout(10*time.Millisecond)).(*stealingPool)
+		defer pool.Stop()
+		for i := 0; i < 100; i++ {
+			pool.Submit(&Task{ID: "idle", F: func() {}})
+		}
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(int32(1), pool.workersAlive.Load())
+		var c atomic.Int32
+		pool.SubmitAndWait(&Task{ID: "again", F: func() { c.Inc() }})
+		assert.Equal(int32(1), c.Load())
+	})
+}
+
+func Test_StealingWorkerTakeOverflow(t *testing.T) {
+	assert := assert.New(t)
+	p := newStealingPool("test", toOptions(WithWorkStealing(), WithMaxWorkers(2), WithQueueSize(4))).(*stealingPool)
+	thief, victim := p.workers[0], p.workers[1]
+	// a full own queue, as if submitters filled it right after it was found empty
+	thief.deque = newTaskDeque(0)
+	for i := 0; i < 4; i++ {
+		assert.True(victim.deque.pushBack(&Task{ID: strconv.Itoa(i)}))
+	}
+	var taken []string
+	for task := thief.take(); task != nil; task = thief.take() {
+		taken = append(taken, task.ID)
+	}
+	// stolen tasks that do not fit are still taken, none is lost
+	assert.ElementsMatch([]string{"0", "1", "2", "3"}, taken)
+}
@@ -43,19 +43,22 @@ func (w *worker) execute(task *Task) {
 func (w *worker) stop(callable func(chan *Task)) {
 	defer callable(w.tasks)
 	w.stopCh <- struct{}{}
+	<-w.pool.slots
 	w.pool.workersKilled.Inc()
 	w.pool.workersAlive.Dec()
 }
 
 func (w *worker) process() {
+	if w.pool.opts.OnWorkerStart != nil {
+		w.pool.opts.OnWorkerStart()
+	}
 	var task *Task
 	for {
 		select {
 		case <-w.stopCh:
 			return
 		case task = <-w.tasks:
-			task.F()
-			w.pool.tasksConsumed.Inc()
+			w.pool.runTask(task)
 			// 将w注册到readyWorkers
 			w.pool.readyWorkers <- w
 		}
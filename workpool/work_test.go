@@ -30,23 +30,26 @@ import (
 func Test_NewWorker(t *testing.T) {
 	assert := assert.New(t)
 	ctx, cancel := context.WithCancel(context.Background())
-	w := NewWorker(
-		&workerPool{
-			name:                "test",
-			maxWorkers:          2,
-			tasks:               make(chan *Task, tasksCapacity),
-			readyWorkers:        make(chan *worker, readyWorkerQueueSize),
-			idleTimeout:         time.Second * 5,
-			onDispatcherStopped: make(chan struct{}),
-			stopped:             *atomic.NewBool(false),
-			workersAlive:        *atomic.NewInt32(0),
-			workersCreated:      *atomic.NewInt32(0),
-			workersKilled:       *atomic.NewInt32(0),
-			tasksConsumed:       *atomic.NewInt32(0),
-			ctx:                 ctx,
-			cancel:              cancel,
-		},
-	)
+	pool := &workerPool{
+		name:                "test",
+		opts:                toOptions(WithMaxWorkers(2), WithIdleTimeout(time.Second*5)),
+		tasks:               make(chan *Task, DefaultQueueSize),
+		readyWorkers:        make(chan *worker, 4),
+		slots:               make(chan struct{}, 2),
+		onDispatcherStopped: make(chan struct{}),
+		stopped:             *atomic.NewBool(false),
+		workersAlive:        *atomic.NewInt32(0),
+		workersCreated:      *atomic.NewInt32(0),
+		workersKilled:       *atomic.NewInt32(0),
+		tasksConsumed:       *atomic.NewInt32(0),
+		ctx:                 ctx,
+		cancel:              cancel,
+		taskCtx:             ctx,
+		taskCancel:          cancel,
+	}
+	// the worker holds a slot until it is stopped
+	pool.slots <- struct{}{}
+	w := NewWorker(pool)
 	t.Run("Task Set, Setting task", func(t *testing.T) {
 		w.execute(&Task{
 			ID: "taskid1",
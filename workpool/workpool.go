@@ -25,104 +25,190 @@ import (
 	"go.uber.org/atomic"
 )
 
-const (
-	// Queue 默认长度
-	readyWorkerQueueSize = 32
-	// Task 数据
-	tasksCapacity = 8
-	// 队列为空时，sleep 5ms
-	sleepInterval = time.Millisecond * 5
-)
-
 type workerPool struct {
 	name                string        // 工作协程池名称
-	maxWorkers          int           // 最大工程协程池数据
+	opts                Options       // 协程池配置
 	tasks               chan *Task    // Task channel
 	readyWorkers        chan *worker  // 当前活跃工作协程
-	idleTimeout         time.Duration // 空闲goroutine回收时间
-	onDispatcherStopped chan struct{} // stop信号
+	slots               chan struct{} // worker配额，每个存活的worker占用一个
+	onDispatcherStopped chan struct{} // dispatcher退出时关闭
+	held                chan *Task    // dispatcher停止时已从队列取出、尚未执行的任务
 	stopped             atomic.Bool   // 标记 协程池是否关闭
 	workersAlive        atomic.Int32  // 当前协程使用数
 	workersCreated      atomic.Int32  // 当前协程创建数
 	workersKilled       atomic.Int32  // 当前协程完成数： 包括被kill
 	tasksConsumed       atomic.Int32  // 处理的任务数
+	tasksSubmitted      atomic.Int32  // 提交的任务数
+	tasksPanicked       atomic.Int32  // panic的任务数
+	tasksDiscarded      atomic.Int32  // 未执行就被丢弃的任务数
 	ctx                 context.Context
 	cancel              context.CancelFunc
+	taskCtx             context.Context // 传给任务的ctx，强制停止时取消
+	taskCancel          context.CancelFunc
 }
 
+// NewWorkerPool creates a pool with at most maxWorkers workers
 func NewWorkerPool(name string, maxWorkers int, idleTimeout time.Duration) Pool {
-	if maxWorkers < 1 {
-		maxWorkers = 1
+	return New(name, WithMaxWorkers(maxWorkers), WithIdleTimeout(idleTimeout))
+}
+
+// New creates a pool configured by opts
+func New(name string, opts ...Option) Pool {
+	o := toOptions(opts...)
+	if o.WorkStealing {
+		return newStealingPool(name, o)
 	}
 	ctx, cancel := context.WithCancel(context.Background())
+	taskCtx, taskCancel := context.WithCancel(context.Background())
 	pool := &workerPool{
 		name:                name,
-		maxWorkers:          maxWorkers,
-		tasks:               make(chan *Task, tasksCapacity),
-		readyWorkers:        make(chan *worker, readyWorkerQueueSize),
-		idleTimeout:         idleTimeout,
+		opts:                o,
+		tasks:               make(chan *Task, o.QueueSize),
+		readyWorkers:        make(chan *worker, o.MaxWorkers),
+		slots:               make(chan struct{}, o.MaxWorkers),
 		onDispatcherStopped: make(chan struct{}),
+		held:                make(chan *Task, 1),
 		stopped:             *atomic.NewBool(false),
 		workersAlive:        *atomic.NewInt32(0),
 		workersCreated:      *atomic.NewInt32(0),
 		workersKilled:       *atomic.NewInt32(0),
 		tasksConsumed:       *atomic.NewInt32(0),
+		tasksSubmitted:      *atomic.NewInt32(0),
+		tasksPanicked:       *atomic.NewInt32(0),
+		tasksDiscarded:      *atomic.NewInt32(0),
 		ctx:                 ctx,
 		cancel:              cancel,
+		taskCtx:             taskCtx,
+		taskCancel:          taskCancel,
+	}
+	// 预热常驻worker
+	for i := 0; i < o.MinWorkers; i++ {
+		pool.slots <- struct{}{}
+		pool.readyWorkers <- NewWorker(pool)
 	}
 	go pool.dispatch()
 	return pool
 }
 
 func (p *workerPool) Submit(task *Task) {
-	if task == nil || p.Stopped() {
-		return
+	_ = p.SubmitContext(context.Background(), task)
+}
+
+// SubmitContext puts the task into the queue, it blocks while the queue is full
+// until ctx is done or the pool is stopped
+func (p *workerPool) SubmitContext(ctx context.Context, task *Task) error {
+	if task == nil {
+		return nil
 	}
-	p.tasks <- task
+	if err := p.submit(ctx, task); err != nil {
+		return err
+	}
+	p.tasksSubmitted.Inc()
+	return nil
 }
 
-func (p *workerPool) SubmitAndWait(task *Task) {
-	if task == nil || p.Stopped() {
-		return
-	}
-	worker := p.mustGetWorker()
-	doneChan := make(chan struct{})
-	worker.execute(&Task{
-		ID: task.ID,
-		F: func() {
-			task.F()
-			close(doneChan)
-		},
-	})
-	<-doneChan
-}
-
-// 返回可用worker
-func (p *workerPool) mustGetWorker() *worker {
-	var worker *worker
-	for {
-		select {
-		// 获得一个worker
-		case worker = <-p.readyWorkers:
-			return worker
-		default:
-			if int(p.workersAlive.Load()) >= p.maxWorkers {
-				// 没有可用worker
-				time.Sleep(sleepInterval)
-				continue
+func (p *workerPool) submit(ctx context.Context, task *Task) error {
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	select {
+	case p.tasks <- task:
+		return nil
+	default:
+	}
+	switch p.opts.RejectPolicy {
+	case RejectAbort:
+		return ErrQueueFull
+	case RejectCallerRuns:
+		p.runTask(task)
+		return nil
+	case RejectDiscardOldest:
+		for {
+			select {
+			case p.tasks <- task:
+				return nil
+			case old := <-p.tasks:
+				// 丢弃最早的任务
+				p.discard(old)
+			case <-p.ctx.Done():
+				return ErrPoolStopped
 			}
-			w := NewWorker(p)
-			return w
 		}
 	}
+	select {
+	case p.tasks <- task:
+		return nil
+	case <-p.ctx.Done():
+		return ErrPoolStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySubmit puts the task into the queue without blocking,
+// it returns ErrQueueFull if there is no room for the task
+func (p *workerPool) TrySubmit(task *Task) error {
+	if task == nil {
+		return nil
+	}
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	select {
+	case p.tasks <- task:
+		p.tasksSubmitted.Inc()
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (p *workerPool) SubmitAndWait(task *Task) {
+	_ = p.SubmitAndWaitContext(context.Background(), task)
+}
+
+// SubmitAndWaitContext runs the task on a worker without queueing and waits for it, see Pool
+func (p *workerPool) SubmitAndWaitContext(ctx context.Context, task *Task) error {
+	if task == nil {
+		return nil
+	}
+	if p.Stopped() {
+		return ErrPoolStopped
+	}
+	worker, err := p.getWorker(ctx)
+	if err != nil {
+		return err
+	}
+	p.tasksSubmitted.Inc()
+	wrapped, errCh := waitable(task)
+	worker.execute(wrapped)
+	return waitTask(ctx, errCh)
+}
+
+// getWorker 返回可用worker：优先复用空闲worker，未达上限时创建新worker，
+// 否则阻塞直到有worker空闲、有worker退出释放配额、ctx结束或协程池停止
+func (p *workerPool) getWorker(ctx context.Context) (*worker, error) {
+	select {
+	case worker := <-p.readyWorkers:
+		return worker, nil
+	default:
+	}
+	select {
+	case worker := <-p.readyWorkers:
+		return worker, nil
+	case p.slots <- struct{}{}:
+		return NewWorker(p), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.ctx.Done():
+		return nil, ErrPoolStopped
+	}
 }
 
 func (p *workerPool) dispatch() {
-	defer func() {
-		p.onDispatcherStopped <- struct{}{}
-	}()
+	defer close(p.onDispatcherStopped)
 
-	idleTimeoutTimer := time.NewTimer(p.idleTimeout)
+	idleTimeoutTimer := time.NewTimer(p.opts.IdleTimeout)
 	defer idleTimeoutTimer.Stop()
 	var (
 		worker *worker
@@ -130,16 +216,21 @@ func (p *workerPool) dispatch() {
 	)
 
 	for {
-		idleTimeoutTimer.Reset(p.idleTimeout)
+		idleTimeoutTimer.Reset(p.opts.IdleTimeout)
 		select {
 		case <-p.ctx.Done():
 			return
 		case task = <-p.tasks:
-			worker := p.mustGetWorker()
+			worker, err := p.getWorker(p.ctx)
+			if err != nil {
+				// pool is stopping, hand the task already taken from the queue to StopContext
+				p.held <- task
+				return
+			}
 			worker.execute(task)
 		case <-idleTimeoutTimer.C:
-			// 超时, kill掉worker
-			if p.workersAlive.Load() > 0 {
+			// 超时, kill掉worker, 保留常驻worker
+			if int(p.workersAlive.Load()) > p.opts.MinWorkers {
 				select {
 				case worker = <-p.readyWorkers:
 					worker.stop(func(chan *Task) {})
@@ -151,6 +242,20 @@ func (p *workerPool) dispatch() {
 	}
 }
 
+// runTask runs the task with hooks in the current goroutine
+func (p *workerPool) runTask(task *Task) {
+	if p.opts.runTask(p.taskCtx, task) {
+		p.tasksPanicked.Inc()
+	}
+	p.tasksConsumed.Inc()
+}
+
+// discard drops a task which will not be run
+func (p *workerPool) discard(task *Task) {
+	p.tasksDiscarded.Inc()
+	p.opts.discard(task)
+}
+
 func (p *workerPool) Stopped() bool {
 	return p.stopped.Load()
 }
@@ -168,13 +273,14 @@ func (p *workerPool) stopWorkers() {
 	wg.Wait()
 }
 
-// consumedRemainingTasks consumes all buffered tasks in the channel
-func (p *workerPool) consumedRemainingTasks() {
-	for {
+// consumeRemainingTasks consumes the held task and buffered tasks in the channel until ctx is done
+func (p *workerPool) consumeRemainingTasks(ctx context.Context) {
+	for ctx.Err() == nil {
 		select {
+		case task := <-p.held:
+			p.runTask(task)
 		case task := <-p.tasks:
-			task.F()
-			p.tasksConsumed.Inc()
+			p.runTask(task)
 		default:
 			return
 		}
@@ -183,15 +289,53 @@ func (p *workerPool) consumedRemainingTasks() {
 
 // Stop tells the dispatcher to exit with pending tasks done.
 func (p *workerPool) Stop() {
+	_, _ = p.StopContext(context.Background())
+}
+
+// StopContext tells the dispatcher to exit and waits for pending tasks until ctx is done.
+// If ctx is done first, the context passed to running tasks is cancelled and
+// the tasks not started yet are returned along with ctx.Err().
+// It returns nothing if the pool is already stopped.
+func (p *workerPool) StopContext(ctx context.Context) ([]*Task, error) {
 	if p.stopped.Swap(true) {
-		return
+		return nil, nil
 	}
 	// close dispatcher
 	p.cancel()
-	// wait dispatcher's exit
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// wait dispatcher's exit
+		<-p.onDispatcherStopped
+		// close all workers
+		p.stopWorkers()
+		// consume remaining tasks
+		p.consumeRemainingTasks(ctx)
+	}()
+	select {
+	case <-done:
+		if ctx.Err() == nil {
+			p.taskCancel()
+			return nil, nil
+		}
+	case <-ctx.Done():
+	}
+	p.taskCancel()
+	// the dispatcher exits without running tasks, so it does not keep us long
 	<-p.onDispatcherStopped
-	// close all workers
-	p.stopWorkers()
-	// consume remaining tasks
-	p.consumedRemainingTasks()
+	var remaining []*Task
+	for {
+		select {
+		case task := <-p.held:
+			remaining = append(remaining, task)
+		case task := <-p.tasks:
+			remaining = append(remaining, task)
+		default:
+			for _, task := range remaining {
+				p.discard(task)
+			}
+			return remaining, ctx.Err()
+		}
+	}
 }
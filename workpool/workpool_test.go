@@ -18,6 +18,8 @@
 package workpool
 
 import (
+	"context"
+	"errors"
 	"runtime"
 	"strconv"
 	"testing"
@@ -142,3 +144,164 @@ func Test_PoolStoped(t *testing.T) {
 		assert.True(ret)
 	})
 }
+
+func Test_PoolTrySubmit(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 1, time.Second*5)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	// occupy the only worker
+	pool.Submit(&Task{ID: "block", F: func() {
+		close(started)
+		<-block
+	}})
+	<-started
+
+	t.Run("queue full, should return ErrQueueFull", func(t *testing.T) {
+		fill := func() error {
+			var err error
+			// dispatcher holds one task while waiting for a worker
+			for i := 0; i <= DefaultQueueSize+1 && err == nil; i++ {
+				err = pool.TrySubmit(&Task{ID: "try" + strconv.Itoa(i), F: func() {}})
+			}
+			return err
+		}
+		assert.Equal(ErrQueueFull, fill())
+		// the dispatcher may have taken one task after the queue was filled
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(ErrQueueFull, fill())
+	})
+
+	t.Run("queue full, SubmitContext should return when ctx is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := pool.SubmitContext(ctx, &Task{ID: "ctx", F: func() {}})
+		assert.Equal(context.DeadlineExceeded, err)
+	})
+
+	close(block)
+	pool.Stop()
+
+	t.Run("pool stopped, should return ErrPoolStopped", func(t *testing.T) {
+		assert.Equal(ErrPoolStopped, pool.TrySubmit(&Task{ID: "stopped", F: func() {}}))
+		assert.Equal(ErrPoolStopped, pool.SubmitContext(context.Background(), &Task{ID: "stopped", F: func() {}}))
+	})
+}
+
+func Test_PoolSubmitAndWaitBusy(t *testing.T) {
+	assert := assert.New(t)
+	pool := NewDefaultPool("test", 1, time.Second*5)
+	defer pool.Stop()
+	var c atomic.Int32
+	for i := 0; i < 10; i++ {
+		pool.Submit(&Task{ID: "inc" + strconv.Itoa(i), F: func() {
+			time.Sleep(time.Millisecond)
+			c.Inc()
+		}})
+	}
+	pool.SubmitAndWait(&Task{ID: "wait", F: func() {
+		c.Inc()
+	}})
+	assert.True(c.Load() >= 1)
+}
+
+func Test_PoolSubmitAndWaitContext(t *testing.T) {
+	pools := map[string]func() Pool{
+		"worker":   func() Pool { return New("test", WithMaxWorkers(1)) },
+		"stealing": func() Pool { return New("test", WithMaxWorkers(1), WithWorkStealing()) },
+	}
+	for name, newPool := range pools {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			pool := newPool()
+			errTask := errors.New("task failed")
+			assert.Equal(errTask, pool.SubmitAndWaitContext(context.Background(), &Task{ID: "fail", Run: func(ctx context.Context) error {
+				return errTask
+			}}))
+			assert.Error(pool.SubmitAndWaitContext(context.Background(), &Task{ID: "panic", F: func() { panic("boom") }}))
+
+			// the only worker is busy, the caller gives up at its deadline
+			started, release := make(chan struct{}), make(chan struct{})
+			pool.Submit(&Task{ID: "busy", F: func() {
+				close(started)
+				<-release
+			}})
+			<-started
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			assert.Equal(context.DeadlineExceeded, pool.SubmitAndWaitContext(ctx, &Task{ID: "wait", F: func() {}}))
+			close(release)
+
+			pool.Stop()
+			assert.Equal(ErrPoolStopped, pool.SubmitAndWaitContext(context.Background(), &Task{ID: "stopped", F: func() {}}))
+		})
+	}
+}
+
+func Test_PoolStopContext(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("all tasks done before deadline", func(t *testing.T) {
+		pool := NewDefaultPool("test", 2, time.Second*5)
+		var c atomic.Int32
+		for i := 0; i < 10; i++ {
+			pool.Submit(&Task{ID: "inc" + strconv.Itoa(i), F: func() { c.Inc() }})
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		remaining, err := pool.StopContext(ctx)
+		assert.NoError(err)
+		assert.Empty(remaining)
+		assert.Equal(int32(10), c.Load())
+		remaining, err = pool.StopContext(ctx)
+		assert.NoError(err)
+		assert.Empty(remaining)
+	})
+
+	t.Run("deadline exceeded, running task cancelled and unstarted tasks returned", func(t *testing.T) {
+		pool := NewDefaultPool("test", 1, time.Second*5)
+		started := make(chan struct{})
+		cancelled := make(chan struct{})
+		pool.Submit(&Task{ID: "long", Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		}})
+		<-started
+		for i := 0; i < 3; i++ {
+			pool.Submit(&Task{ID: "pending" + strconv.Itoa(i), F: func() {}})
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		remaining, err := pool.StopContext(ctx)
+		assert.Equal(context.DeadlineExceeded, err)
+		<-cancelled
+		assert.Len(remaining, 3)
+	})
+
+	t.Run("deadline exceeded, task held by dispatcher is not run", func(t *testing.T) {
+		pool := NewDefaultPool("test", 1, time.Second*5)
+		started := make(chan struct{})
+		wait := func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		pool.Submit(&Task{ID: "long", Run: func(ctx context.Context) error {
+			close(started)
+			return wait(ctx)
+		}})
+		<-started
+		pool.Submit(&Task{ID: "held", Run: wait})
+		// let the dispatcher take the task and wait for a worker
+		time.Sleep(20 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		remaining, err := pool.StopContext(ctx)
+		assert.Equal(context.DeadlineExceeded, err)
+		assert.True(time.Since(start) < time.Second)
+		assert.Len(remaining, 1)
+		assert.Equal("held", remaining[0].ID)
+	})
+}